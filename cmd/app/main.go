@@ -3,36 +3,246 @@ package main
 import (
 	"context"
 	"log"
+	"log/slog"
 	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"kaspContainers/internal/app"
 	"kaspContainers/internal/backoff"
 	"kaspContainers/internal/config"
+	"kaspContainers/internal/cron"
+	"kaspContainers/internal/dlq"
 	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/logging"
 	"kaspContainers/internal/processing"
+	"kaspContainers/internal/tracing"
+	"kaspContainers/internal/wal"
+	"kaspContainers/internal/webhook"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
 
 	cfg := config.Load()
-	q := jobqueue.NewQueue(cfg.QueueSize)
-	proc := processing.RandomProcessor{ErrorRate: cfg.ErrorRate}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+	slog.SetDefault(logger)
+	duplicates, err := jobqueue.ParseDuplicatePolicy(cfg.DuplicatePolicy)
+	if err != nil {
+		fatal("invalid config", "err", err)
+	}
+	weights, err := jobqueue.ParseWeights(cfg.PriorityWeights)
+	if err != nil {
+		fatal("invalid config", "err", err)
+	}
+	opts := jobqueue.Options{BufferSize: cfg.QueueSize, Duplicates: duplicates, Weights: weights}
+	switch cfg.Store {
+	case "memory":
+	case "file":
+		store, err := jobqueue.OpenFileStore(cfg.StorePath, wal.SyncPolicy(cfg.WALFsync))
+		if err != nil {
+			fatal("store open failed", "err", err)
+		}
+		defer store.Close()
+		opts.Store = store
+	default:
+		fatal("unknown store", "store", cfg.Store)
+	}
+	if cfg.WALDir != "" {
+		journal, err := wal.Open(wal.Options{
+			Dir:          cfg.WALDir,
+			Sync:         wal.SyncPolicy(cfg.WALFsync),
+			SyncInterval: time.Duration(cfg.WALFsyncInterval) * time.Millisecond,
+		})
+		if err != nil {
+			fatal("wal open failed", "err", err)
+		}
+		defer journal.Close()
+		opts.Journal = journal
+	}
+	q, err := jobqueue.Open(opts)
+	if err != nil {
+		fatal("queue open failed", "err", err)
+	}
+	proc := processing.Legacy(processing.RandomProcessor{ErrorRate: cfg.ErrorRate})
 	bo := backoff.ExponentialJitter{Base: 50 * time.Millisecond, Max: 5 * time.Second, Jitter: 50 * time.Millisecond}
-	application := app.New(cfg, q, proc, bo)
+	appOpts := []app.Option{app.WithLogger(logger)}
+	if cfg.DLQPath != "" {
+		deadLetters, err := dlq.Open(cfg.DLQPath, wal.SyncPolicy(cfg.WALFsync))
+		if err != nil {
+			fatal("dlq open failed", "err", err)
+		}
+		defer deadLetters.Close()
+		appOpts = append(appOpts, app.WithDeadLetters(deadLetters))
+	}
+	if cfg.SchedulesPath != "" {
+		schedules, err := cron.Open(cfg.SchedulesPath, wal.SyncPolicy(cfg.WALFsync), q)
+		if err != nil {
+			fatal("schedules open failed", "err", err)
+		}
+		defer schedules.Close()
+		appOpts = append(appOpts, app.WithSchedules(schedules))
+	}
+	hookOpts := webhook.Options{
+		Secret:      cfg.WebhookSecret,
+		Backoff:     backoff.ExponentialJitter{Base: time.Second, Max: 5 * time.Minute, Jitter: 500 * time.Millisecond},
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Timeout:     time.Duration(cfg.WebhookTimeoutMs) * time.Millisecond,
+		Retention:   time.Duration(cfg.WebhookRetentionS) * time.Second,
+	}
+	if cfg.WebhooksPath != "" {
+		hooks, err := webhook.Open(cfg.WebhooksPath, wal.SyncPolicy(cfg.WALFsync), hookOpts)
+		if err != nil {
+			fatal("webhooks open failed", "err", err)
+		}
+		defer hooks.Close()
+		appOpts = append(appOpts, app.WithWebhooks(hooks))
+	} else {
+		appOpts = append(appOpts, app.WithWebhooks(webhook.New(hookOpts)))
+	}
+	var exporter tracing.Exporter
+	switch cfg.TraceExporter {
+	case "none":
+	case "file":
+		exporter, err = tracing.NewFileExporter(cfg.TraceFile)
+		if err != nil {
+			fatal("trace exporter open failed", "err", err)
+		}
+	case "otlp":
+		exporter = &tracing.OTLPExporter{Endpoint: cfg.TraceOTLPEndpoint, ServiceName: cfg.TraceServiceName}
+	default:
+		fatal("unknown trace exporter", "exporter", cfg.TraceExporter)
+	}
+	tracer := tracing.NewTracer(tracing.Options{Exporter: exporter})
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			logger.Warn("trace exporter shutdown failed", "err", err)
+		}
+	}()
+	appOpts = append(appOpts, app.WithTracer(tracer))
+	queues, err := config.ParseQueues(cfg.Queues)
+	if err != nil {
+		fatal("invalid config", "err", err)
+	}
+	for _, qc := range queues {
+		spec, closeQueue, err := openNamedQueue(cfg, qc, opts)
+		if err != nil {
+			fatal("queue open failed", "queue", qc.Name, "err", err)
+		}
+		defer closeQueue()
+		appOpts = append(appOpts, app.WithQueue(spec))
+	}
+	application := app.New(cfg, q, proc, bo, appOpts...)
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		<-sigCh
-		log.Println("shutting down...")
+		logger.Info("shutting down")
 		cancel()
+		<-sigCh
+		logger.Warn("second signal: forced exit")
+		os.Exit(1)
 	}()
 
-	_ = application.Run(ctx, ":8080")
+	if _, err := application.Run(ctx, ":8080"); err != nil {
+		fatal("run failed", "err", err)
+	}
+}
+
+// openNamedQueue создаёт именованную очередь по описанию qc. Параметры, не заданные в qc, берутся из
+// основной очереди. Журнал (при WAL_DIR) пишется в подкаталог queues/<name>, а файлы хранилища (STORE=file),
+// DLQ и расписаний — рядом с файлами основной очереди с суффиксом -<name>. Возвращаемая функция закрывает их.
+func openNamedQueue(cfg config.Config, qc config.QueueConfig, base jobqueue.Options) (app.QueueSpec, func(), error) {
+	opts := jobqueue.Options{BufferSize: cfg.QueueSize, Duplicates: base.Duplicates, Weights: base.Weights}
+	if qc.Size > 0 {
+		opts.BufferSize = qc.Size
+	}
+	var closers []func() error
+	closeQueue := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			_ = closers[i]()
+		}
+	}
+	sync := wal.SyncPolicy(cfg.WALFsync)
+	if cfg.Store == "file" {
+		store, err := jobqueue.OpenFileStore(config.QueuePath(cfg.StorePath, qc.Name), sync)
+		if err != nil {
+			return app.QueueSpec{}, nil, err
+		}
+		closers = append(closers, store.Close)
+		opts.Store = store
+	}
+	if cfg.WALDir != "" {
+		journal, err := wal.Open(wal.Options{
+			Dir:          filepath.Join(cfg.WALDir, "queues", qc.Name),
+			Sync:         sync,
+			SyncInterval: time.Duration(cfg.WALFsyncInterval) * time.Millisecond,
+		})
+		if err != nil {
+			closeQueue()
+			return app.QueueSpec{}, nil, err
+		}
+		closers = append(closers, journal.Close)
+		opts.Journal = journal
+	}
+	q, err := jobqueue.Open(opts)
+	if err != nil {
+		closeQueue()
+		return app.QueueSpec{}, nil, err
+	}
+	var deadLetters *dlq.Store
+	if cfg.DLQPath != "" {
+		deadLetters, err = dlq.Open(config.QueuePath(cfg.DLQPath, qc.Name), sync)
+		if err != nil {
+			closeQueue()
+			return app.QueueSpec{}, nil, err
+		}
+		closers = append(closers, deadLetters.Close)
+	}
+	var schedules *cron.Scheduler
+	if cfg.SchedulesPath != "" {
+		schedules, err = cron.Open(config.QueuePath(cfg.SchedulesPath, qc.Name), sync, q)
+		if err != nil {
+			closeQueue()
+			return app.QueueSpec{}, nil, err
+		}
+		closers = append(closers, schedules.Close)
+	}
+	errorRate := cfg.ErrorRate
+	if qc.ErrorRate >= 0 {
+		errorRate = qc.ErrorRate
+	}
+	bo := backoff.ExponentialJitter{Base: 50 * time.Millisecond, Max: 5 * time.Second, Jitter: 50 * time.Millisecond}
+	if qc.BackoffBaseMs > 0 {
+		bo.Base = time.Duration(qc.BackoffBaseMs) * time.Millisecond
+	}
+	if qc.BackoffMaxMs > 0 {
+		bo.Max = time.Duration(qc.BackoffMaxMs) * time.Millisecond
+	}
+	spec := app.QueueSpec{
+		Name:        qc.Name,
+		Queue:       q,
+		Processor:   processing.Legacy(processing.RandomProcessor{ErrorRate: errorRate}),
+		Backoff:     bo,
+		Workers:     qc.Workers,
+		DeadLetters: deadLetters,
+		Schedules:   schedules,
+	}
+	return spec, closeQueue, nil
+}
+
+// fatal логирует ошибку запуска и завершает процесс.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -3,49 +3,154 @@ package app
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"kaspContainers/internal/backoff"
 	"kaspContainers/internal/config"
+	"kaspContainers/internal/cron"
+	"kaspContainers/internal/dlq"
+	"kaspContainers/internal/events"
+	"kaspContainers/internal/health"
 	"kaspContainers/internal/jobqueue"
 	"kaspContainers/internal/processing"
+	"kaspContainers/internal/tracing"
+	"kaspContainers/internal/webhook"
 )
 
 // App инкапсулирует конфигурацию сервиса, очередь задач,
 // процессор обработки и политику бэкоффа, а также управляет HTTP-сервером
 // и жизненным циклом воркеров.
 type App struct {
-	cfg  config.Config
-	q    *jobqueue.Queue
-	proc processing.Processor
-	bo   backoff.Policy
+	cfg     config.Config
+	q       *jobqueue.Queue
+	proc    processing.Processor
+	bo      backoff.Policy
+	dlq     *dlq.Store
+	sched   *cron.Scheduler
+	metrics *appMetrics
+	log     *slog.Logger
+	tracer  *tracing.Tracer
+	events  *events.Broker
+	hooks   *webhook.Dispatcher
+	watch   *watchdog
+	pool    *workerPool
+	live    *health.Registry // проверки GET /livez
+	ready   *health.Registry // проверки GET /readyz
+	specs   []QueueSpec      // именованные очереди из опций WithQueue
+	queues  []*namedQueue    // именованные очереди по имени
+
+	// stopCtx отменяется при остановке, когда воркеры уже завершены: прерывает долгие ожидания клиентов.
+	stopCtx context.Context
+	stop    context.CancelFunc
+	// workCtx отменяется по истечении срока дообработки: воркеры перестают брать задания, выполняющиеся отменяются.
+	workCtx   context.Context
+	abortWork context.CancelFunc
+	shutdown  shutdownStats
+}
+
+// Option настраивает необязательные зависимости App.
+type Option func(*App)
+
+// WithDeadLetters задаёт хранилище dead-letter очереди (по умолчанию — в памяти).
+func WithDeadLetters(d *dlq.Store) Option {
+	return func(a *App) { a.dlq = d }
+}
+
+// WithLogger задаёт логгер приложения (по умолчанию — slog.Default()).
+func WithLogger(l *slog.Logger) Option {
+	return func(a *App) { a.log = l }
+}
+
+// WithTracer задаёт трассировщик (по умолчанию спаны не экспортируются).
+func WithTracer(t *tracing.Tracer) Option {
+	return func(a *App) { a.tracer = t }
+}
+
+// WithWebhooks задаёт диспетчер уведомлений о завершении заданий. Без него
+// (или без секрета подписи в конфигурации) callback_url не принимается.
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(a *App) { a.hooks = d }
+}
+
+// WithSchedules задаёт планировщик повторяющихся заданий (по умолчанию — в памяти).
+func WithSchedules(s *cron.Scheduler) Option {
+	return func(a *App) { a.sched = s }
 }
 
 // New создаёт и возвращает новый экземпляр приложения.
-func New(cfg config.Config, q *jobqueue.Queue, proc processing.Processor, bo backoff.Policy) *App {
-	return &App{cfg: cfg, q: q, proc: proc, bo: bo}
+func New(cfg config.Config, q *jobqueue.Queue, proc processing.Processor, bo backoff.Policy, opts ...Option) *App {
+	a := &App{cfg: cfg, q: q, proc: proc, bo: bo, dlq: dlq.New(), log: slog.Default(), tracer: tracing.NewTracer(tracing.Options{}),
+		watch: newWatchdog(), pool: newWorkerPool(), live: health.NewRegistry(), ready: health.NewRegistry()}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.sched == nil {
+		a.sched = cron.New(q)
+	}
+	if a.hooks == nil {
+		a.hooks = webhook.New(webhook.Options{Secret: cfg.WebhookSecret, Backoff: bo})
+	}
+	if a.metrics == nil {
+		a.metrics = &appMetrics{metricSet: newMetricSet(), queue: DefaultQueue}
+	}
+	a.metrics.collect(a)
+	a.newNamedQueues()
+	a.stopCtx, a.stop = context.WithCancel(context.Background())
+	a.workCtx, a.abortWork = context.WithCancel(context.Background())
+	a.registerHealthChecks()
+	q.Observe(a.metrics.observe)
+	a.events = events.NewBroker(cfg.EventsBuffer)
+	q.Observe(a.publishEvent)
+	q.Observe(a.shutdown.observe)
+	return a
 }
 
-// Run запускает HTTP-сервер, воркеры и ожидает завершения по ctx.
-func (a *App) Run(ctx context.Context, addr string) error {
+// Run восстанавливает задания из контрольной точки прошлой остановки, запускает HTTP-сервер и воркеры
+// основной и именованных очередей, ожидает завершения по ctx и возвращает итог остановки.
+func (a *App) Run(ctx context.Context, addr string) (ShutdownReport, error) {
 	acceptingMu := &sync.Mutex{}
 	accepting := true
 	mux := a.buildMux(acceptingMu, &accepting)
-	srv := &http.Server{Addr: addr, Handler: mux}
+	srv := &http.Server{Addr: addr, Handler: a.withRequestID(mux)}
 
 	var wgWorkers sync.WaitGroup
-	a.startWorkers(&wgWorkers)
+	a.startProcessing(&wgWorkers)
+	for _, nq := range a.queues {
+		nq.app.startProcessing(&nq.wg)
+	}
+	a.hooks.Start()
 	a.startServer(srv)
 
 	<-ctx.Done()
-	a.gracefulStop(srv, acceptingMu, &accepting, &wgWorkers)
-	return nil
+	return a.gracefulStop(srv, acceptingMu, &accepting, &wgWorkers), nil
 }
 
-// buildMux настраивает маршруты HTTP: swagger, docs, healthz и enqueue.
+// startProcessing восстанавливает контрольную точку очереди и запускает её воркеры, планировщик
+// расписаний, очистку результатов и автоскейлер.
+func (a *App) startProcessing(wg *sync.WaitGroup) {
+	n, err := a.restoreCheckpoint()
+	if n > 0 {
+		a.log.Info("checkpoint restored", "jobs", n)
+	}
+	if err != nil {
+		a.log.Error("checkpoint restore failed", "path", a.cfg.CheckpointPath, "err", err)
+	}
+	a.startWorkers(wg)
+	a.sched.Start()
+	go a.expireResults()
+	if a.cfg.Autoscale {
+		go a.autoscale()
+	}
+}
+
+// buildMux настраивает маршруты HTTP: swagger, docs, healthz, livez, readyz, webhooks, маршруты основной очереди
+// (см. queueRoutes), список очередей /queues и маршруты именованных очередей под /queues/{name}.
 func (a *App) buildMux(acceptingMu *sync.Mutex, accepting *bool) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.Handle("/swagger/", http.StripPrefix("/swagger/", http.FileServer(http.Dir("docs/swagger"))))
@@ -54,6 +159,22 @@ func (a *App) buildMux(acceptingMu *sync.Mutex, accepting *bool) *http.ServeMux
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("/livez", a.probeHandler(a.live))
+	mux.HandleFunc("/readyz", a.probeHandler(a.ready))
+	mux.HandleFunc("/webhooks/deliveries", a.handleDeliveries)
+	mux.HandleFunc("/webhooks/deliveries/{id}", a.handleDelivery)
+	mux.HandleFunc("/webhooks/deliveries/{id}/redeliver", a.handleRedeliver)
+	a.queueRoutes(mux, acceptingMu, accepting)
+	mux.HandleFunc("/queues", a.handleQueues)
+	mux.Handle("/queues/{name}/", a.queueHandler(mux, acceptingMu, accepting))
+	return mux
+}
+
+// queueRoutes регистрирует маршруты одной очереди: metrics, enqueue, events, queue, jobs, dlq, schedules,
+// диагностику, управление пулом воркеров и паузы. Используется для основной и каждой именованной очереди.
+func (a *App) queueRoutes(mux *http.ServeMux, acceptingMu *sync.Mutex, accepting *bool) {
+	a.registerAcceptingCheck(acceptingMu, accepting)
+	mux.HandleFunc("/metrics", a.handleMetrics)
 	mux.HandleFunc("/enqueue", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -71,15 +192,30 @@ func (a *App) buildMux(acceptingMu *sync.Mutex, accepting *bool) *http.ServeMux
 		}
 		acceptingMu.Unlock()
 
+		log := a.reqLog(r)
 		var req struct {
-			ID         string `json:"id"`
-			Payload    string `json:"payload"`
-			MaxRetries int    `json:"max_retries"`
+			ID          string `json:"id"`
+			Payload     string `json:"payload"`
+			MaxRetries  int    `json:"max_retries"`
+			Priority    string `json:"priority"`
+			Type        string `json:"type"`
+			RunAt       string `json:"run_at"`
+			DelayMs     int64  `json:"delay_ms"`
+			TimeoutMs   int64  `json:"timeout_ms"`
+			CallbackURL string `json:"callback_url"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
+		key := r.Header.Get("Idempotency-Key")
+		if len(key) > 128 {
+			http.Error(w, "idempotency key too long", http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" {
+			req.ID = key
+		}
 		if req.ID == "" {
 			http.Error(w, "id required", http.StatusBadRequest)
 			return
@@ -96,81 +232,294 @@ func (a *App) buildMux(acceptingMu *sync.Mutex, accepting *bool) *http.ServeMux
 			http.Error(w, "max_retries must be between 0 and 10", http.StatusBadRequest)
 			return
 		}
-		job := jobqueue.Job{ID: req.ID, Payload: req.Payload, MaxRetries: req.MaxRetries}
+		if limit := a.jobTimeoutMax(); req.TimeoutMs < 0 || time.Duration(req.TimeoutMs)*time.Millisecond > limit {
+			http.Error(w, fmt.Sprintf("timeout_ms must be between 0 and %d", limit.Milliseconds()), http.StatusBadRequest)
+			return
+		}
+		if len(req.Type) > maxJobTypeLen {
+			http.Error(w, "type too long", http.StatusBadRequest)
+			return
+		}
+		prio, err := jobqueue.ParsePriority(req.Priority)
+		if err != nil {
+			http.Error(w, "priority must be one of high, normal, low", http.StatusBadRequest)
+			return
+		}
+		var runAt time.Time
+		switch {
+		case req.RunAt != "" && req.DelayMs != 0:
+			http.Error(w, "run_at and delay_ms are mutually exclusive", http.StatusBadRequest)
+			return
+		case req.RunAt != "":
+			if runAt, err = time.Parse(time.RFC3339, req.RunAt); err != nil {
+				http.Error(w, "run_at must be RFC3339", http.StatusBadRequest)
+				return
+			}
+		case req.DelayMs < 0:
+			http.Error(w, "delay_ms must not be negative", http.StatusBadRequest)
+			return
+		case req.DelayMs > 0:
+			runAt = time.Now().Add(time.Duration(req.DelayMs) * time.Millisecond)
+		}
+		if req.CallbackURL != "" {
+			if msg := a.validCallbackURL(req.CallbackURL); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+		ctx := r.Context()
+		if parent, err := tracing.ParseTraceparent(r.Header.Get("traceparent")); err == nil {
+			ctx = tracing.ContextWithSpanContext(ctx, parent)
+		}
+		_, span := a.tracer.Start(ctx, "enqueue", tracing.KindProducer)
+		defer span.End()
+		span.SetAttr("job.id", req.ID)
+		span.SetAttr("job.priority", string(prio))
+		if req.Type != "" {
+			span.SetAttr("job.type", req.Type)
+		}
+		job := jobqueue.Job{
+			ID:             req.ID,
+			Payload:        req.Payload,
+			MaxRetries:     req.MaxRetries,
+			IdempotencyKey: key,
+			Priority:       prio,
+			Type:           req.Type,
+			RunAt:          runAt,
+			Timeout:        time.Duration(req.TimeoutMs) * time.Millisecond,
+			RequestID:      requestIDFrom(r.Context()),
+			TraceParent:    span.SpanContext().Traceparent(),
+			CallbackURL:    req.CallbackURL,
+		}
 		if err := a.q.Enqueue(job); err != nil {
+			span.SetError(err)
+			var dup *jobqueue.DuplicateError
+			if errors.As(err, &dup) {
+				if dup.Replay {
+					log.Info("enqueue replay", "job_id", dup.Existing.ID, "state", dup.Existing.State)
+					writeJSON(w, http.StatusOK, newJobStatus(dup.Existing))
+					return
+				}
+				log.Info("enqueue rejected: duplicate", "job_id", dup.Existing.ID, "state", dup.Existing.State)
+				http.Error(w, "duplicate job id", http.StatusConflict)
+				return
+			}
 			if err == jobqueue.ErrClosed {
-				log.Printf("enqueue rejected: closed id=%s", req.ID)
+				log.Warn("enqueue rejected: closed", "job_id", req.ID)
 				http.Error(w, "queue closed", http.StatusServiceUnavailable)
 				return
 			}
 			if err == jobqueue.ErrFull {
-				log.Printf("enqueue rejected: full id=%s", req.ID)
+				log.Warn("enqueue rejected: full", "job_id", req.ID)
 				http.Error(w, "queue full", http.StatusTooManyRequests)
 				return
 			}
-			log.Printf("enqueue error id=%s: %v", req.ID, err)
+			log.Error("enqueue error", "job_id", req.ID, "err", err)
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		log.Printf("enqueued id=%s max_retries=%d", req.ID, req.MaxRetries)
+		status := jobqueue.StateQueued
+		if runAt.After(time.Now()) {
+			status = jobqueue.StateScheduled
+		}
+		log.Info("enqueued", "job_id", req.ID, "max_retries", req.MaxRetries, "priority", prio, "state", status)
 		w.WriteHeader(http.StatusAccepted)
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "queued"})
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": string(status)})
 	})
-	return mux
+	mux.HandleFunc("/events", a.handleEvents)
+	mux.HandleFunc("/queue", a.handleQueueStats)
+	mux.HandleFunc("/jobs", a.handleJobs)
+	mux.HandleFunc("/jobs/{id}", a.handleJob)
+	mux.HandleFunc("/jobs/{id}/wait", a.handleJobWait)
+	mux.HandleFunc("/jobs/{id}/result", a.handleJobResult)
+	mux.HandleFunc("/dlq", a.handleDLQ)
+	mux.HandleFunc("/dlq/redrive", a.handleDLQRedriveBatch)
+	mux.HandleFunc("/dlq/{id}", a.handleDLQEntry)
+	mux.HandleFunc("/dlq/{id}/redrive", a.handleDLQRedrive)
+	mux.HandleFunc("/schedules", a.handleSchedules)
+	mux.HandleFunc("/schedules/{name}", a.handleSchedule)
+	mux.HandleFunc("/debug/workers", a.handleWorkers)
+	mux.HandleFunc("/admin/workers", a.handleAdminWorkers)
+	mux.HandleFunc("/admin/pause", a.handleAdminPause)
+	mux.HandleFunc("/admin/resume", a.handleAdminResume)
 }
 
-// startWorkers запускает пул воркеров, которые читают задания из очереди
-// и обрабатывают их с ретраями по политике бэкоффа.
+// startWorkers запускает пул из WORKERS воркеров, которые читают задания из очереди
+// и обрабатывают их с ретраями по политике бэкоффа. Размер пула затем меняется через ResizeWorkers.
 func (a *App) startWorkers(wg *sync.WaitGroup) {
-	wg.Add(a.cfg.Workers)
-	for i := 0; i < a.cfg.Workers; i++ {
-		go func() {
-			defer wg.Done()
-			for {
-				job, ok := a.q.Next()
-				if !ok {
-					return
-				}
-				start := time.Now()
-				a.q.UpdatesStateRunning(job.ID)
-				log.Printf("start id=%s", job.ID)
-				maxAttempts := job.MaxRetries + 1
-				for attempt := 1; attempt <= maxAttempts; attempt++ {
-					ok, _ := a.proc.Process(job.ID, job.Payload)
-					if ok {
-						a.q.UpdatesStateDone(job.ID)
-						log.Printf("done id=%s attempts=%d dur=%s", job.ID, attempt, time.Since(start))
-						break
-					}
-					if attempt == maxAttempts {
-						a.q.UpdatesStateFailed(job.ID)
-						log.Printf("failed id=%s attempts=%d dur=%s", job.ID, attempt, time.Since(start))
-						break
-					}
-					time.Sleep(a.bo.Delay(attempt))
-				}
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+	a.pool.wg = wg
+	a.resizeLocked(a.cfg.Workers)
+}
+
+// processJob выполняет задание с ретраями. Каждая попытка ограничена таймаутом задания (см. runAttempt);
+// превышение таймаута — повторяемая ошибка с кодом timeout. Класс ошибки определяет повтор: permanent завершает
+// задание сразу, rate_limited с RetryAfter заменяет задержку бэкоффа. Отмена задания через Queue.Cancel
+// прерывает текущую попытку и ожидание бэкоффа; так же действует истечение срока дообработки
+// при остановке. Спаны ожидания в очереди, обработки,
+// каждой попытки и бэкоффа продолжают трассу, сохранённую в задании при постановке.
+func (a *App) processJob(worker int, job jobqueue.Job) {
+	log := a.jobLog(job.ID, job.RequestID).With("worker", worker)
+	ctx, cancel := context.WithCancel(a.workCtx)
+	defer cancel()
+	if !a.q.Acquire(job.ID, cancel) {
+		log.Info("skip cancelled", "state", jobqueue.StateCancelled)
+		return
+	}
+	defer a.q.Release(job.ID)
+	a.metrics.busy.Add(1, a.metrics.queue)
+	defer a.metrics.busy.Add(-1, a.metrics.queue)
+	a.pool.addBusy(1)
+	defer a.pool.addBusy(-1)
+
+	if parent, err := tracing.ParseTraceparent(job.TraceParent); err == nil {
+		ctx = tracing.ContextWithSpanContext(ctx, parent)
+		log = log.With("trace_id", parent.TraceID.String())
+	}
+	if ji, err := a.q.Get(job.ID); err == nil {
+		_, wait := a.tracer.StartAt(ctx, "queue.wait", tracing.KindInternal, ji.EnqueuedAt)
+		wait.SetAttr("job.id", job.ID)
+		wait.EndAt(ji.StartedAt)
+	}
+	ctx, span := a.tracer.Start(ctx, "job.process", tracing.KindConsumer)
+	span.SetAttr("job.id", job.ID)
+	span.SetAttr("worker", worker)
+	defer span.End()
+
+	start := time.Now()
+	timeout := a.jobTimeout(job)
+	log.Info("start", "state", jobqueue.StateRunning)
+	maxAttempts := job.MaxRetries + 1
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		at := jobqueue.Attempt{Number: attempt, StartedAt: time.Now()}
+		actx, aspan := a.tracer.Start(ctx, "job.attempt", tracing.KindInternal)
+		aspan.SetAttr("attempt", attempt)
+		res, err := a.runAttempt(actx, log, worker, processing.Job{ID: job.ID, Payload: job.Payload, Type: job.Type, Attempt: attempt}, timeout)
+		at.Duration = time.Since(at.StartedAt)
+		a.pool.observeAttempt(at.Duration)
+		if ctx.Err() != nil {
+			a.metrics.observeAttempt("cancelled", at.Duration)
+			aspan.SetError(ctx.Err())
+			aspan.End()
+			if a.workCtx.Err() != nil {
+				a.interrupt(log, span, job, attempt, start)
+				return
+			}
+			at.Error = ctx.Err().Error()
+			span.SetAttr("state", string(jobqueue.StateCancelled))
+			a.q.UpdatesAttempt(job.ID, at)
+			a.q.UpdatesStateCancelled(job.ID)
+			log.Info("cancelled", "state", jobqueue.StateCancelled, "attempt", attempt, "duration_ms", time.Since(start).Milliseconds())
+			return
+		}
+		if err == nil && len(res.Data) > a.resultMaxBytes() {
+			err = processing.Permanent("result_too_large", fmt.Sprintf("result of %d bytes exceeds limit of %d", len(res.Data), a.resultMaxBytes()))
+		}
+		if err == nil {
+			a.metrics.observeAttempt("success", at.Duration)
+			aspan.End()
+			span.SetAttr("state", string(jobqueue.StateDone))
+			a.q.UpdatesAttempt(job.ID, at)
+			if res.Data != "" {
+				a.q.UpdatesResult(job.ID, a.newResult(res))
 			}
-		}()
+			a.q.UpdatesStateDone(job.ID)
+			a.notify(log, job)
+			log.Info("done", "state", jobqueue.StateDone, "attempt", attempt, "duration_ms", time.Since(start).Milliseconds(),
+				"result_bytes", len(res.Data))
+			return
+		}
+		a.metrics.observeAttempt("failure", at.Duration)
+		aspan.SetError(err)
+		aspan.End()
+		class, retryAfter := processing.Classify(err)
+		at.Error, at.Code, at.Class = err.Error(), processing.ErrorCode(err), string(class)
+		var pe *panicError
+		if errors.As(err, &pe) {
+			at.Stack = pe.stack
+			log.Error("processor panic", "attempt", attempt, "err", err, "stack", pe.stack)
+		}
+		final := attempt == maxAttempts || class == processing.ClassPermanent
+		if !final {
+			// Задержка, предложенная сервисом при ограничении частоты, заменяет политику бэкоффа.
+			at.RetryAfter = a.bo.Delay(attempt)
+			if retryAfter > 0 {
+				at.RetryAfter = retryAfter
+			}
+		}
+		a.q.UpdatesAttempt(job.ID, at)
+		if final {
+			span.SetAttr("state", string(jobqueue.StateFailed))
+			span.SetError(err)
+			a.q.UpdatesStateFailed(job.ID)
+			a.deadLetter(log, job)
+			a.notify(log, job)
+			log.Warn("failed", "state", jobqueue.StateFailed, "attempt", attempt, "err", err, "code", at.Code, "class", class,
+				"duration_ms", time.Since(start).Milliseconds())
+			return
+		}
+		delay := at.RetryAfter
+		a.metrics.backoff.Observe(delay.Seconds(), a.metrics.queue)
+		log.Debug("retry scheduled", "attempt", attempt, "err", err, "code", at.Code, "class", class, "backoff_ms", delay.Milliseconds())
+		_, bspan := a.tracer.Start(ctx, "job.backoff", tracing.KindInternal)
+		bspan.SetAttr("attempt", attempt)
+		bspan.SetAttr("delay_ms", delay.Milliseconds())
+		slept := sleepCtx(ctx, delay)
+		bspan.End()
+		if !slept {
+			if a.workCtx.Err() != nil {
+				a.interrupt(log, span, job, attempt, start)
+				return
+			}
+			span.SetAttr("state", string(jobqueue.StateCancelled))
+			a.q.UpdatesStateCancelled(job.ID)
+			log.Info("cancelled", "state", jobqueue.StateCancelled, "attempt", attempt, "duration_ms", time.Since(start).Milliseconds())
+			return
+		}
+	}
+}
+
+// interrupt откладывает задание, прерванное по истечении срока дообработки, до контрольной точки.
+// Задание не отменяется и остаётся в состоянии running: при следующем запуске его восстановит
+// контрольная точка или журнал очереди.
+func (a *App) interrupt(log *slog.Logger, span *tracing.Span, job jobqueue.Job, attempt int, start time.Time) {
+	span.SetAttr("state", string(jobqueue.StateRunning))
+	a.shutdown.interrupt(job)
+	log.Warn("interrupted by shutdown", "state", jobqueue.StateRunning, "attempt", attempt, "duration_ms", time.Since(start).Milliseconds())
+}
+
+// deadLetter помещает исчерпавшее попытки задание в DLQ вместе с историей попыток.
+func (a *App) deadLetter(log *slog.Logger, job jobqueue.Job) {
+	ji, err := a.q.Get(job.ID)
+	if err != nil {
+		log.Error("dlq: job state lookup failed", "err", err)
+	}
+	e := dlq.Entry{Job: job, Attempts: ji.History, LastError: ji.LastError, FailedAt: time.Now()}
+	if err := a.dlq.Add(e); err != nil {
+		log.Error("dlq: add failed", "err", err)
+	}
+}
+
+// sleepCtx ждёт d или отмены ctx. Возвращает false, если ожидание прервано отменой.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
 	}
 }
 
 // startServer запускает HTTP-сервер в отдельной горутине.
 func (a *App) startServer(srv *http.Server) {
 	go func() {
-		log.Printf("listening on %s", srv.Addr)
+		a.log.Info("listening", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+			a.log.Error("server error", "err", err)
+			os.Exit(1)
 		}
 	}()
 }
-
-// gracefulStop прекращает приём новых задач, закрывает очередь,
-// дожидается завершения воркеров и останавливает HTTP-сервер.
-func (a *App) gracefulStop(srv *http.Server, acceptingMu *sync.Mutex, accepting *bool, wg *sync.WaitGroup) {
-	acceptingMu.Lock()
-	*accepting = false
-	acceptingMu.Unlock()
-	a.q.Close()
-	wg.Wait()
-	_ = srv.Shutdown(context.Background())
-}
@@ -1,25 +1,55 @@
 package app
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"kaspContainers/internal/backoff"
 	"kaspContainers/internal/config"
+	"kaspContainers/internal/dlq"
+	"kaspContainers/internal/health"
 	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/processing"
+	"kaspContainers/internal/tracing"
+	"kaspContainers/internal/wal"
+	"kaspContainers/internal/webhook"
 )
 
 // dummyProc всегда успешно "обрабатывает" задачу без задержки
 type dummyProc struct{}
 
-func (dummyProc) Process(jobID string, payload string) (bool, time.Duration) {
-	return true, 0
+func (dummyProc) Process(ctx context.Context, job processing.Job) (processing.Result, error) {
+	return processing.Result{}, nil
+}
+
+// blockingProc обрабатывает задачу, пока её не отменят.
+type blockingProc struct{ started chan string }
+
+func (p blockingProc) Process(ctx context.Context, job processing.Job) (processing.Result, error) {
+	p.started <- job.ID
+	<-ctx.Done()
+	return processing.Result{}, ctx.Err()
+}
+
+// failProc всегда завершает обработку неуспехом.
+type failProc struct{}
+
+func (failProc) Process(ctx context.Context, job processing.Job) (processing.Result, error) {
+	return processing.Result{}, processing.ErrFailed
 }
 
 func newTestApp() *App {
@@ -89,5 +119,1312 @@ func TestRunAndShutdown(t *testing.T) {
 	srv := httptest.NewServer(a.buildMux(&sync.Mutex{}, boolPtr(true)))
 	defer srv.Close()
 	cancel()
-	_ = a.Run(ctx, ":0")
+	_, _ = a.Run(ctx, ":0")
+}
+
+func TestJobStatus(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"s1","payload":"abc"}`))
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/s1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var st jobStatus
+	if err := json.NewDecoder(rr.Body).Decode(&st); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if st.State != jobqueue.StateDone || st.Attempts != 1 || st.PayloadSize != 3 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+	if st.EnqueuedAt.IsZero() || st.StartedAt.IsZero() || st.FinishedAt.IsZero() {
+		t.Fatalf("expected timestamps to be set: %+v", st)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	a.q.Close()
+	wg.Wait()
+}
+
+func TestListJobs(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	for _, id := range []string{"l1", "l2", "l3"} {
+		if err := a.q.Enqueue(jobqueue.Job{ID: id}); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs?state=queued&limit=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var page jobList
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(page.Jobs) != 2 || page.Jobs[0].ID != "l1" || page.NextCursor == "" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs?limit=2&cursor="+page.NextCursor, nil))
+	page = jobList{}
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(page.Jobs) != 1 || page.Jobs[0].ID != "l3" || page.NextCursor != "" {
+		t.Fatalf("unexpected second page: %+v", page)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs?state=bogus", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	a := newTestApp()
+	proc := blockingProc{started: make(chan string, 1)}
+	a.proc = proc
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+
+	_ = a.q.Enqueue(jobqueue.Job{ID: "c1", MaxRetries: 3})
+	<-proc.started
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/jobs/c1", nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+	a.q.Close()
+	wg.Wait()
+	if ji, _ := a.q.Get("c1"); ji.State != jobqueue.StateCancelled || ji.Attempts != 1 {
+		t.Fatalf("expected cancelled after one attempt, got %+v", ji)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/jobs/c1", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestDeadLetterAndRedrive(t *testing.T) {
+	a := newTestApp()
+	a.proc = failProc{}
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+
+	_ = a.q.Enqueue(jobqueue.Job{ID: "d1", Payload: "p", MaxRetries: 1})
+	time.Sleep(30 * time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/dlq/d1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var e dlqEntryView
+	if err := json.NewDecoder(rr.Body).Decode(&e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Payload != "p" || len(e.Attempts) != 2 || e.Attempts[1].Error == "" {
+		t.Fatalf("unexpected dlq entry: %+v", e)
+	}
+
+	a.proc = dummyProc{}
+	a.q.Pause()
+	_ = a.q.Enqueue(jobqueue.Job{ID: "d0", Payload: "p"})
+	_ = a.dlq.Add(dlq.Entry{Job: jobqueue.Job{ID: "d0", Payload: "p"}, FailedAt: time.Now().Add(-time.Minute)})
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/dlq/redrive", bytes.NewBufferString(`{"prefix":"d"}`)))
+	var batch struct {
+		Redriven []string         `json:"redriven"`
+		Failed   []redriveFailure `json:"failed"`
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&batch)
+	if rr.Code != http.StatusOK || len(batch.Failed) != 1 || batch.Failed[0].ID != "d0" ||
+		len(batch.Redriven) != 1 || batch.Redriven[0] != "d1" || a.dlq.Len() != 1 {
+		t.Fatalf("expected active job to fail without stopping the batch, got %d %+v len=%d", rr.Code, batch, a.dlq.Len())
+	}
+	a.q.Resume()
+	time.Sleep(20 * time.Millisecond)
+	if st := a.q.StatesSnapshot()["d1"]; st != jobqueue.StateDone {
+		t.Fatalf("expected redriven job done, got %v", st)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/dlq/d1/redrive", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+	a.q.Close()
+	wg.Wait()
+}
+
+func TestEnqueueDuplicates(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	post := func(body, key string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := post(`{"payload":"p"}`, "key-1"); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 with key as id, got %d", rr.Code)
+	}
+	rr := post(`{"payload":"p"}`, "key-1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 replay, got %d", rr.Code)
+	}
+	var st jobStatus
+	if err := json.NewDecoder(rr.Body).Decode(&st); err != nil || st.ID != "key-1" || st.State != jobqueue.StateQueued {
+		t.Fatalf("unexpected replay body: %+v err=%v", st, err)
+	}
+	if rr := post(`{"id":"key-1","payload":"other"}`, ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for different payload, got %d", rr.Code)
+	}
+}
+
+func TestQueueStats(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"p1","priority":"high"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"p2","priority":"urgent"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown priority, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/queue", nil))
+	var st queueStats
+	if err := json.NewDecoder(rr.Body).Decode(&st); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if st.Depth != 1 || st.Capacity != 8 || st.Lanes[jobqueue.PriorityHigh] != 1 || st.Lanes[jobqueue.PriorityLow] != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestEnqueueDelayed(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"s1","delay_ms":60000}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+	var resp map[string]string
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["status"] != "scheduled" {
+		t.Fatalf("expected scheduled status, got %v", resp)
+	}
+	if ji, _ := a.q.Get("s1"); ji.State != jobqueue.StateScheduled || ji.RunAt.IsZero() {
+		t.Fatalf("unexpected job info: %+v", ji)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"s2","delay_ms":5,"run_at":"2030-01-01T00:00:00Z"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	a.q.Close()
+}
+
+func TestSchedulesCRUD(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, path, bytes.NewBufferString(body)))
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/schedules", `{"name":"nightly","spec":"0 3 * * *","payload":"p"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodPost, "/schedules", `{"name":"nightly","spec":"0 3 * * *"}`); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/schedules", `{"name":"bad","spec":"0 3 * *"}`); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+	rr := do(http.MethodPut, "/schedules/nightly", `{"spec":"@every 30s","overlap":"queue"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var view scheduleView
+	_ = json.NewDecoder(rr.Body).Decode(&view)
+	if view.Spec != "@every 30s" || view.Overlap != "queue" || view.CatchUp != "once" || view.NextRun.IsZero() {
+		t.Fatalf("unexpected schedule: %+v", view)
+	}
+
+	rr = do(http.MethodGet, "/schedules", "")
+	var list struct {
+		Schedules []scheduleView `json:"schedules"`
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Schedules) != 1 || list.Schedules[0].Name != "nightly" {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+	if rr := do(http.MethodDelete, "/schedules/nightly", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/schedules/nightly", ""); rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	for _, body := range []string{`{"id":"m1"}`, `{"id":"m1","payload":"other"}`} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body)))
+	}
+	a.q.Close()
+	wg.Wait()
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	out := rr.Body.String()
+	for _, want := range []string{
+		`jobqueue_enqueue_total{queue="default",result="accepted"} 1`,
+		`jobqueue_enqueue_total{queue="default",result="duplicate"} 1`,
+		`jobqueue_transitions_total{queue="default",state="done"} 1`,
+		`job_attempts_total{queue="default",result="success"} 1`,
+		`job_latency_seconds_count{queue="default",state="done"} 1`,
+		`jobqueue_depth{queue="default",priority="normal"} 0`,
+		`jobqueue_capacity{queue="default"} 8`,
+		`workers{queue="default"} 1`,
+		`workers_busy{queue="default"} 0`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	var logs bytes.Buffer
+	cfg := config.Config{Workers: 1, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), dummyProc{}, bo,
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	h := a.withRequestID(a.buildMux(&sync.Mutex{}, boolPtr(true)))
+
+	req := httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"r1"}`))
+	req.Header.Set("X-Request-ID", "req-42")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted || rr.Header().Get("X-Request-ID") != "req-42" {
+		t.Fatalf("unexpected response %d request id %q", rr.Code, rr.Header().Get("X-Request-ID"))
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if id := rr.Header().Get("X-Request-ID"); len(id) != 16 {
+		t.Fatalf("expected generated request id, got %q", id)
+	}
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	var jobLines int
+	dec := json.NewDecoder(&logs)
+	for dec.More() {
+		var rec map[string]any
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decode log: %v", err)
+		}
+		if rec["job_id"] != "r1" {
+			continue
+		}
+		jobLines++
+		if rec["request_id"] != "req-42" {
+			t.Errorf("log line without request id: %v", rec)
+		}
+	}
+	if jobLines < 3 { // enqueued, start, done
+		t.Fatalf("expected job log lines, got %d", jobLines)
+	}
+	if ji, _ := a.q.Get("r1"); ji.RequestID != "req-42" {
+		t.Fatalf("expected request id stored on job, got %q", ji.RequestID)
+	}
+}
+
+// spanRecorder запоминает экспортированные спаны.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (r *spanRecorder) Export(_ context.Context, spans []tracing.SpanData) error {
+	r.mu.Lock()
+	r.spans = append(r.spans, spans...)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error { return nil }
+
+// traceProc запоминает контекст спана, переданный в обработчик, и всегда завершается неуспехом.
+type traceProc struct{ seen chan tracing.SpanContext }
+
+func (p traceProc) Process(ctx context.Context, job processing.Job) (processing.Result, error) {
+	p.seen <- tracing.SpanContextFromContext(ctx)
+	return processing.Result{}, processing.ErrFailed
+}
+
+func TestTracePropagation(t *testing.T) {
+	rec := &spanRecorder{}
+	tracer := tracing.NewTracer(tracing.Options{Exporter: rec})
+	proc := traceProc{seen: make(chan tracing.SpanContext, 4)}
+	cfg := config.Config{Workers: 1, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo, WithTracer(tracer))
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"t1","max_retries":1}`))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string][]tracing.SpanData)
+	byID := make(map[string]tracing.SpanData)
+	for _, s := range rec.spans {
+		if s.TraceID != traceID {
+			t.Errorf("span %s in foreign trace %s", s.Name, s.TraceID)
+		}
+		byName[s.Name] = append(byName[s.Name], s)
+		byID[s.SpanID] = s
+	}
+	for name, n := range map[string]int{"enqueue": 1, "queue.wait": 1, "job.process": 1, "job.attempt": 2, "job.backoff": 1} {
+		if len(byName[name]) != n {
+			t.Fatalf("expected %d %q spans, got %d", n, name, len(byName[name]))
+		}
+	}
+	enq, process := byName["enqueue"][0], byName["job.process"][0]
+	if enq.ParentID != "00f067aa0ba902b7" || process.ParentID != enq.SpanID || byName["queue.wait"][0].ParentID != enq.SpanID {
+		t.Fatalf("unexpected parents: enqueue=%s process=%s", enq.ParentID, process.ParentID)
+	}
+	for _, s := range append(byName["job.attempt"], byName["job.backoff"]...) {
+		if s.ParentID != process.SpanID {
+			t.Errorf("span %s parent %s, want %s", s.Name, s.ParentID, process.SpanID)
+		}
+	}
+	if process.Error == "" || process.Attributes["state"] != string(jobqueue.StateFailed) {
+		t.Fatalf("expected failed process span, got %+v", process)
+	}
+	sc := <-proc.seen
+	if _, ok := byID[sc.SpanID.String()]; !ok || byID[sc.SpanID.String()].Name != "job.attempt" {
+		t.Fatalf("processor did not receive attempt span context: %v", sc)
+	}
+}
+
+// readSSE читает из потока n событий и возвращает их идентификаторы, имена и тела.
+func readSSE(t *testing.T, r *bufio.Reader, n int) (ids, names []string, data []streamEvent) {
+	t.Helper()
+	var id, name string
+	for len(names) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var se streamEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &se); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			ids, names, data = append(ids, id), append(names, name), append(data, se)
+		}
+	}
+	return ids, names, data
+}
+
+func TestEventsStream(t *testing.T) {
+	a := newTestApp()
+	srv := httptest.NewServer(a.buildMux(&sync.Mutex{}, boolPtr(true)))
+	defer srv.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	open := func(query, lastID string) *http.Response {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events"+query, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+			t.Fatalf("unexpected response %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+		return resp
+	}
+	resp := open("?job_id=e1", "")
+	defer resp.Body.Close()
+
+	for _, id := range []string{"other", "e1"} {
+		rr := httptest.NewRecorder()
+		a.buildMux(&sync.Mutex{}, boolPtr(true)).ServeHTTP(rr,
+			httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"`+id+`"}`)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("enqueue %s: %d", id, rr.Code)
+		}
+	}
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	ids, names, data := readSSE(t, bufio.NewReader(resp.Body), 3)
+	if names[0] != "enqueued" || names[1] != "transition" || names[2] != "transition" {
+		t.Fatalf("unexpected event names %v", names)
+	}
+	for _, se := range data {
+		if se.JobID != "e1" {
+			t.Fatalf("filter leaked event for %s", se.JobID)
+		}
+	}
+	if data[1].PrevState != jobqueue.StateQueued || data[1].State != jobqueue.StateRunning || data[2].State != jobqueue.StateDone {
+		t.Fatalf("unexpected transitions %+v", data[1:])
+	}
+
+	resumed := open("?state=done", ids[0])
+	defer resumed.Body.Close()
+	_, _, data = readSSE(t, bufio.NewReader(resumed.Body), 2)
+	if data[0].JobID != "other" || data[1].JobID != "e1" || data[1].State != jobqueue.StateDone {
+		t.Fatalf("unexpected resumed events %+v", data)
+	}
+
+	rr := httptest.NewRecorder()
+	a.handleEvents(rr, httptest.NewRequest(http.MethodGet, "/events?state=bogus", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid state, got %d", rr.Code)
+	}
+}
+
+func TestCompletionWebhook(t *testing.T) {
+	received := make(chan jobNotification, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify("s3cret", r.Header.Get(webhook.HeaderTimestamp), body, r.Header.Get(webhook.HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var n jobNotification
+		_ = json.Unmarshal(body, &n)
+		received <- n
+	}))
+	defer receiver.Close()
+
+	cfg := config.Config{Workers: 1, QueueSize: 8, WebhookSecret: "s3cret"}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), failProc{}, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue",
+		bytes.NewBufferString(`{"id":"w1","max_retries":1,"callback_url":"ftp://example.com"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-http callback, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue",
+		bytes.NewBufferString(`{"id":"w1","max_retries":1,"callback_url":"`+receiver.URL+`"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rr.Code)
+	}
+
+	a.hooks.Start()
+	defer a.hooks.Stop()
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	select {
+	case n := <-received:
+		if n.Event != "job.failed" || n.JobID != "w1" || n.State != jobqueue.StateFailed || n.Attempts != 2 || len(n.History) != 2 {
+			t.Fatalf("unexpected notification %+v", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var list deliveryList
+	for {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/webhooks/deliveries?job_id=w1", nil))
+		_ = json.NewDecoder(rr.Body).Decode(&list)
+		if len(list.Deliveries) == 1 && list.Deliveries[0].State == webhook.StateDelivered {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery not recorded as delivered: %+v", list)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	id := strconv.FormatUint(list.Deliveries[0].ID, 10)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/webhooks/deliveries/"+id, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 for delivery, got %d", rr.Code)
+	}
+
+	plain := newTestApp()
+	rr = httptest.NewRecorder()
+	plain.buildMux(&sync.Mutex{}, boolPtr(true)).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue",
+		bytes.NewBufferString(`{"id":"w2","callback_url":"`+receiver.URL+`"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without webhook secret, got %d", rr.Code)
+	}
+}
+
+func TestJobWait(t *testing.T) {
+	a := newTestApp()
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"lp"}`)))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("enqueue: %d", rr.Code)
+	}
+
+	for target, want := range map[string]int{
+		"/jobs/lp/wait?timeout=10ms":  http.StatusAccepted,
+		"/jobs/lp/wait?timeout=bogus": http.StatusBadRequest,
+		"/jobs/lp/wait?timeout=1h":    http.StatusBadRequest,
+		"/jobs/nope/wait":             http.StatusNotFound,
+	} {
+		rr = httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", target, want, rr.Code)
+		}
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/lp/wait?timeout=5s", nil))
+		done <- rr
+	}()
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+	select {
+	case rr = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("wait did not return after job finished")
+	}
+	var st jobStatus
+	_ = json.NewDecoder(rr.Body).Decode(&st)
+	if rr.Code != http.StatusOK || st.State != jobqueue.StateDone {
+		t.Fatalf("expected 200 done, got %d %s", rr.Code, st.State)
+	}
+}
+
+func TestJobResult(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8, ResultMaxBytes: 16, ResultTTLSeconds: 60}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		switch job.Payload {
+		case "big":
+			return processing.Result{Data: strings.Repeat("x", 17)}, nil
+		case "none":
+			return processing.Result{}, nil
+		case "bad":
+			return processing.Result{}, processing.NewError("invalid_payload", "payload is not valid")
+		}
+		return processing.Result{Data: `{"sum":3}`, ContentType: "application/json"}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	for _, body := range []string{`{"id":"r1"}`, `{"id":"big","payload":"big"}`, `{"id":"none","payload":"none"}`, `{"id":"bad","payload":"bad"}`} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("enqueue %s: %d", body, rr.Code)
+		}
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/r1/result", nil))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 before processing, got %d", rr.Code)
+	}
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/r1/result", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"sum":3}` || rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected result %d %q %q", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	var st jobStatus
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/r1", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&st)
+	if st.ResultSize != 9 || st.ResultExpiresAt.IsZero() {
+		t.Fatalf("expected result metadata in status, got %+v", st)
+	}
+	if ji, _ := a.q.Get("big"); ji.State != jobqueue.StateFailed || ji.History[0].Code != "result_too_large" {
+		t.Fatalf("expected oversized result to fail the job, got %s %+v", ji.State, ji.History)
+	}
+	if ji, _ := a.q.Get("bad"); ji.LastError != "payload is not valid" || ji.History[0].Code != "invalid_payload" {
+		t.Fatalf("expected typed error recorded, got %q %+v", ji.LastError, ji.History)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/none/result", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 for job without result, got %d", rr.Code)
+	}
+
+	a.q.ExpireResults(time.Now().Add(time.Hour))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/r1/result", nil))
+	if rr.Code != http.StatusGone {
+		t.Fatalf("expected 410 for expired result, got %d", rr.Code)
+	}
+}
+
+func TestErrorClasses(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		switch {
+		case job.Payload == "permanent":
+			return processing.Result{}, processing.Permanent("invalid_payload", "payload is not valid")
+		case job.Payload == "limited" && job.Attempt == 1:
+			return processing.Result{}, processing.RateLimited("throttled", "too many requests", 20*time.Millisecond)
+		case job.Payload == "flaky" && job.Attempt < 3:
+			return processing.Result{}, processing.ErrFailed
+		}
+		return processing.Result{}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	for _, body := range []string{
+		`{"id":"p","payload":"permanent","max_retries":3}`,
+		`{"id":"l","payload":"limited","max_retries":1}`,
+		`{"id":"f","payload":"flaky","max_retries":2}`,
+	} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("enqueue %s: %d", body, rr.Code)
+		}
+	}
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	if ji, _ := a.q.Get("p"); ji.State != jobqueue.StateFailed || len(ji.History) != 1 || ji.History[0].Class != "permanent" || ji.History[0].RetryAfter != 0 {
+		t.Fatalf("expected permanent error to stop retries, got %s %+v", ji.State, ji.History)
+	}
+	ji, _ := a.q.Get("l")
+	if ji.State != jobqueue.StateDone || len(ji.History) != 2 {
+		t.Fatalf("expected rate-limited job to succeed on retry, got %s %+v", ji.State, ji.History)
+	}
+	if at := ji.History[0]; at.Class != "rate_limited" || at.RetryAfter != 20*time.Millisecond ||
+		ji.History[1].StartedAt.Sub(at.StartedAt) < 20*time.Millisecond {
+		t.Fatalf("expected retry-after to override backoff, got %+v", ji.History)
+	}
+	if ji, _ := a.q.Get("f"); ji.State != jobqueue.StateDone || len(ji.History) != 3 || ji.History[0].Class != "retryable" {
+		t.Fatalf("expected retryable errors to be retried, got %s %+v", ji.State, ji.History)
+	}
+
+	var st jobStatus
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/l", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&st)
+	if len(st.History) != 2 || st.History[0].Class != "rate_limited" || st.History[0].RetryAfterMs != 20 {
+		t.Fatalf("expected classification in status history, got %+v", st.History)
+	}
+}
+
+func TestAttemptTimeoutAndWatchdog(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8, JobTimeoutMs: 20, JobTimeoutMaxMs: 1000, WatchdogGraceMs: 20}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	release := make(chan struct{})
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		switch {
+		case job.Payload == "hang" && job.Attempt == 1:
+			<-release // игнорирует отмену контекста
+		case job.Payload == "slow":
+			<-ctx.Done()
+			return processing.Result{}, ctx.Err()
+		}
+		return processing.Result{}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"x","payload":"p","timeout_ms":5000}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for timeout_ms above ceiling, got %d", rr.Code)
+	}
+	for _, body := range []string{`{"id":"h","payload":"hang","max_retries":1}`, `{"id":"s","payload":"slow","timeout_ms":30}`} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("enqueue %s: %d", body, rr.Code)
+		}
+	}
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait() // не зависает, хотя процессор первой попытки h не вернулся
+
+	ji, _ := a.q.Get("h")
+	if ji.State != jobqueue.StateDone || len(ji.History) != 2 || ji.History[0].Code != "timeout" || ji.History[0].Class != "retryable" {
+		t.Fatalf("expected hung attempt to be retried after timeout, got %s %+v", ji.State, ji.History)
+	}
+	ji, _ = a.q.Get("s")
+	if ji.State != jobqueue.StateFailed || ji.History[0].Code != "timeout" || ji.History[0].Duration < 30*time.Millisecond {
+		t.Fatalf("expected per-job timeout to fail the attempt, got %s %+v", ji.State, ji.History)
+	}
+
+	var diag workersDiagnostics
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/workers", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&diag)
+	if len(diag.Workers) != 1 || diag.Workers[0].State != "idle" || len(diag.Stuck) != 1 ||
+		diag.Stuck[0].JobID != "h" || !diag.Stuck[0].Overdue || diag.Stuck[0].AbandonedAt.IsZero() {
+		t.Fatalf("expected hung attempt in diagnostics, got %+v", diag)
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for a.watch.stuckCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected stuck attempt to clear after the processor returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		if job.Payload == "boom" && job.Attempt == 1 {
+			panic("boom")
+		}
+		return processing.Result{}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	a.hooks = nil // уведомление через нулевой диспетчер паникует вне попытки
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	_ = a.q.Enqueue(jobqueue.Job{ID: "p", Payload: "boom", MaxRetries: 1})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "w", CallbackURL: "http://example.invalid/hook"})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "after"})
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	a.q.Close()
+	wg.Wait()
+
+	ji, _ := a.q.Get("p")
+	if ji.State != jobqueue.StateDone || len(ji.History) != 2 {
+		t.Fatalf("expected panicking attempt to be retried, got %s %+v", ji.State, ji.History)
+	}
+	if at := ji.History[0]; at.Code != "panic" || at.Error != "panic: boom" || !strings.Contains(at.Stack, "goroutine") {
+		t.Fatalf("expected panic recorded in history, got %+v", at)
+	}
+	if ji, _ := a.q.Get("after"); ji.State != jobqueue.StateDone {
+		t.Fatalf("expected restarted worker slot to keep processing, got %s", ji.State)
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	for _, want := range []string{`worker_panics_total{queue="default",source="processor"} 1`, `worker_panics_total{queue="default",source="worker"} 1`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+	var diag workersDiagnostics
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/workers", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&diag)
+	if w := diag.Workers[0]; w.Panics != 2 || w.Restarts != 1 {
+		t.Fatalf("expected panic counters in diagnostics, got %+v", w)
+	}
+}
+
+func TestDrainTimeoutAndCheckpoint(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8, DrainTimeoutMs: 50, CheckpointPath: filepath.Join(t.TempDir(), "checkpoint.jsonl")}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	started := make(chan string, 1)
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), blockingProc{started: started}, bo)
+	_ = a.q.Enqueue(jobqueue.Job{ID: "running"})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "b1", Payload: "x", Priority: jobqueue.PriorityLow})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "b2", Timeout: time.Second})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "later", RunAt: time.Now().Add(time.Hour)})
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	if id := <-started; id != "running" {
+		t.Fatalf("unexpected first job %s", id)
+	}
+	report := a.gracefulStop(&http.Server{}, &sync.Mutex{}, boolPtr(true), &wg)
+	if !report.TimedOut || report.Interrupted != 1 || report.Cancelled != 0 || report.Checkpointed != 4 ||
+		report.Drained != 0 || report.Dropped != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if ji, _ := a.q.Get("running"); ji.State != jobqueue.StateRunning {
+		t.Fatalf("expected job interrupted at drain deadline to stay running, got %s", ji.State)
+	}
+
+	b := New(cfg, jobqueue.NewQueue(cfg.QueueSize), dummyProc{}, bo)
+	n, err := b.restoreCheckpoint()
+	if err != nil || n != 4 {
+		t.Fatalf("expected 4 jobs restored, got %d %v", n, err)
+	}
+	if ji, _ := b.q.Get("later"); ji.State != jobqueue.StateScheduled || b.q.Len() != 3 {
+		t.Fatalf("expected interrupted, buffered and delayed jobs restored, got %s, len %d", ji.State, b.q.Len())
+	}
+	if job, _ := b.q.Next(); job.ID != "running" {
+		t.Fatalf("expected interrupted job restored first, got %+v", job)
+	}
+	if job, _ := b.q.Next(); job.ID != "b2" || job.Timeout != time.Second {
+		t.Fatalf("expected job fields restored, got %+v", job)
+	}
+	if _, err := os.Stat(cfg.CheckpointPath); !os.IsNotExist(err) {
+		t.Fatalf("expected checkpoint removed after restore, got %v", err)
+	}
+}
+
+func TestDrainTimeoutWithJournal(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Config{Workers: 1, QueueSize: 8, DrainTimeoutMs: 50, WALDir: dir, CheckpointPath: filepath.Join(dir, "checkpoint.jsonl")}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	open := func() (*jobqueue.Queue, *wal.Log) {
+		j, err := wal.Open(wal.Options{Dir: dir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := jobqueue.Open(jobqueue.Options{BufferSize: cfg.QueueSize, Journal: j})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return q, j
+	}
+	q, j := open()
+	started := make(chan string, 1)
+	a := New(cfg, q, blockingProc{started: started}, bo)
+	_ = a.q.Enqueue(jobqueue.Job{ID: "running"})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "b1"})
+	_ = a.q.Enqueue(jobqueue.Job{ID: "later", RunAt: time.Now().Add(time.Hour)})
+
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	<-started
+	report := a.gracefulStop(&http.Server{}, &sync.Mutex{}, boolPtr(true), &wg)
+	if report.Interrupted != 1 || report.Journaled != 3 || report.Checkpointed != 0 || report.Dropped != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, err := os.Stat(cfg.CheckpointPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no checkpoint with journal, got %v", err)
+	}
+	_ = j.Close()
+
+	q, j = open()
+	defer j.Close()
+	for id, want := range map[string]jobqueue.State{"running": jobqueue.StateQueued, "b1": jobqueue.StateQueued, "later": jobqueue.StateScheduled} {
+		if ji, _ := q.Get(id); ji.State != want {
+			t.Fatalf("expected %s recovered from journal as %s, got %s", id, want, ji.State)
+		}
+	}
+	if q.Len() != 2 {
+		t.Fatalf("expected each job recovered once, got len %d", q.Len())
+	}
+}
+
+func TestProbes(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 2, ReadyQueueHighWater: 50}
+	var dbDown bool
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), dummyProc{}, backoff.ExponentialJitter{Base: time.Millisecond},
+		WithReadinessCheck("db", func(context.Context) error {
+			if dbDown {
+				return errors.New("connection refused")
+			}
+			return nil
+		}))
+	accepting := true
+	mux := a.buildMux(&sync.Mutex{}, &accepting)
+	probe := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+	if rr := probe("/readyz"); rr.Code != http.StatusOK || rr.Body.String() != "ok" {
+		t.Fatalf("expected ready, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	_ = a.q.Enqueue(jobqueue.Job{ID: "j1"})
+	dbDown = true
+	rr := probe("/readyz?verbose")
+	var v probeView
+	_ = json.NewDecoder(rr.Body).Decode(&v)
+	failed := map[string]string{}
+	for _, c := range v.Checks {
+		if c.Status != health.StatusOK {
+			failed[c.Name] = c.Error
+		}
+	}
+	if rr.Code != http.StatusServiceUnavailable || v.Status != health.StatusFail || len(v.Checks) != 5 ||
+		len(failed) != 2 || failed["db"] != "connection refused" || failed["queue"] == "" {
+		t.Fatalf("expected queue and db checks to fail, got %d %+v", rr.Code, v)
+	}
+
+	_, _ = a.q.Next()
+	dbDown = false
+	accepting = false
+	if rr := probe("/readyz"); rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), "accepting") {
+		t.Fatalf("expected not ready while draining, got %d %q", rr.Code, rr.Body.String())
+	}
+	if rr := probe("/livez"); rr.Code != http.StatusOK {
+		t.Fatalf("expected live while draining, got %d", rr.Code)
+	}
+}
+
+func TestAutoscaleTarget(t *testing.T) {
+	cases := []struct {
+		name              string
+		size, busy, depth int
+		avg, targetWait   time.Duration
+		lo, hi, want      int
+	}{
+		{"backlog without latency data", 2, 2, 3, 0, time.Second, 1, 10, 5},
+		{"backlog by latency", 2, 2, 10, 200 * time.Millisecond, time.Second, 1, 10, 4},
+		{"backlog capped by max", 2, 2, 100, time.Second, time.Second, 1, 8, 8},
+		{"fast jobs keep size", 4, 1, 2, 10 * time.Millisecond, time.Second, 1, 10, 4},
+		{"idle shrinks by one", 4, 1, 0, time.Second, time.Second, 1, 10, 3},
+		{"idle keeps busy workers", 4, 4, 0, time.Second, time.Second, 1, 10, 4},
+		{"idle floored by min", 2, 0, 0, 0, time.Second, 2, 10, 2},
+	}
+	for _, c := range cases {
+		if got := autoscaleTarget(c.size, c.busy, c.depth, c.avg, c.targetWait, c.lo, c.hi); got != c.want {
+			t.Errorf("%s: got %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResizeWorkers(t *testing.T) {
+	cfg := config.Config{Workers: 1, WorkersMax: 4, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	release := make(chan struct{})
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		<-release
+		return processing.Result{}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	resize := func(body string) (int, poolView) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/admin/workers", bytes.NewBufferString(body)))
+		var v poolView
+		_ = json.NewDecoder(rr.Body).Decode(&v)
+		return rr.Code, v
+	}
+	waitBusy := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for a.newPoolView().Busy != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d busy workers, got %+v", n, a.newPoolView())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if code, _ := resize(`{"size":2}`); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 before workers start, got %d", code)
+	}
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	for _, id := range []string{"a", "b", "c"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(`{"id":"`+id+`","payload":"p"}`)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("enqueue %s: %d", id, rr.Code)
+		}
+	}
+	waitBusy(1)
+	if code, v := resize(`{"size":3}`); code != http.StatusOK || v.Size != 3 || v.Max != 4 {
+		t.Fatalf("expected pool to grow to 3, got %d %+v", code, v)
+	}
+	waitBusy(3)
+	if code, _ := resize(`{"size":5}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for size above max, got %d", code)
+	}
+	if code, v := resize(`{"size":1}`); code != http.StatusOK || v.Size != 1 || v.Stopping != 2 || v.Busy != 3 {
+		t.Fatalf("expected removed workers to finish their jobs, got %d %+v", code, v)
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for a.newPoolView().Stopping != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected stopped workers to exit, got %+v", a.newPoolView())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		for ji, _ := a.q.Get(id); ji.State != jobqueue.StateDone; ji, _ = a.q.Get(id) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to finish after shrink, got %s", id, ji.State)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var diag workersDiagnostics
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/workers", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&diag)
+	if len(diag.Workers) != 1 || diag.Workers[0].Worker != 0 {
+		t.Fatalf("expected a single worker after shrink, got %+v", diag.Workers)
+	}
+	a.q.Close()
+	wg.Wait()
+}
+
+func TestPauseResume(t *testing.T) {
+	cfg := config.Config{Workers: 2, QueueSize: 8}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	var mu sync.Mutex
+	var types []string
+	proc := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		mu.Lock()
+		types = append(types, job.Type)
+		mu.Unlock()
+		return processing.Result{}, nil
+	})
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), proc, bo)
+	mux := a.buildMux(&sync.Mutex{}, boolPtr(true))
+	post := func(path, body string) (int, pauseView) {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+		var v pauseView
+		_ = json.NewDecoder(rr.Body).Decode(&v)
+		return rr.Code, v
+	}
+	waitState := func(id string, want jobqueue.State) {
+		deadline := time.Now().Add(time.Second)
+		for {
+			ji, _ := a.q.Get(id)
+			if ji.State == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s to be %s, got %s", id, want, ji.State)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if code, v := post("/admin/pause", ""); code != http.StatusOK || !v.Paused {
+		t.Fatalf("expected global pause, got %d %+v", code, v)
+	}
+	if code, v := post("/admin/pause", `{"type":"emails"}`); code != http.StatusOK || len(v.Types) != 1 || v.Types[0] != "emails" {
+		t.Fatalf("expected type pause, got %d %+v", code, v)
+	}
+	if code, _ := post("/admin/pause", `{"type":"emails","priority":"low"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for ambiguous target, got %d", code)
+	}
+	if code, _ := post("/admin/resume", `{"priority":"urgent"}`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown priority, got %d", code)
+	}
+	for _, body := range []string{`{"id":"mail","payload":"p","type":"emails"}`, `{"id":"report","payload":"p","type":"reports"}`} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/enqueue", bytes.NewBufferString(body)))
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected intake to continue while paused, got %d", rr.Code)
+		}
+	}
+	var wg sync.WaitGroup
+	a.startWorkers(&wg)
+	time.Sleep(20 * time.Millisecond)
+	if ji, _ := a.q.Get("report"); ji.State != jobqueue.StateQueued {
+		t.Fatalf("expected paused job to wait, got %s", ji.State)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rr.Body.String(), `jobqueue_paused{queue="default",priority="normal"} 1`) {
+		t.Fatalf("expected paused lane gauge, got:\n%s", rr.Body.String())
+	}
+	if code, v := post("/admin/resume", ""); code != http.StatusOK || v.Paused || len(v.Types) != 1 {
+		t.Fatalf("expected global resume to keep type pause, got %d %+v", code, v)
+	}
+	waitState("report", jobqueue.StateDone)
+	if ji, _ := a.q.Get("mail"); ji.State != jobqueue.StateQueued || ji.Attempts != 0 {
+		t.Fatalf("expected paused type to wait without attempts, got %s %d", ji.State, ji.Attempts)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/pause", nil))
+	var v pauseView
+	_ = json.NewDecoder(rr.Body).Decode(&v)
+	if v.Paused || len(v.Types) != 1 {
+		t.Fatalf("unexpected pause state %+v", v)
+	}
+	post("/admin/resume", `{"type":"emails"}`)
+	waitState("mail", jobqueue.StateDone)
+	a.q.Close()
+	wg.Wait()
+	if len(types) != 2 || types[0] != "reports" || types[1] != "emails" {
+		t.Fatalf("expected job type passed to the processor, got %v", types)
+	}
+	var st jobStatus
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/mail", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&st)
+	if st.Type != "emails" {
+		t.Fatalf("expected type in job status, got %+v", st)
+	}
+}
+
+func TestNamedQueues(t *testing.T) {
+	cfg := config.Config{Workers: 1, QueueSize: 8, ResultMaxBytes: 1 << 10}
+	bo := backoff.ExponentialJitter{Base: time.Millisecond, Max: time.Millisecond}
+	emails := processing.ProcessorFunc(func(ctx context.Context, job processing.Job) (processing.Result, error) {
+		return processing.Result{Data: "sent " + job.ID}, nil
+	})
+	deadLetters := dlq.New()
+	a := New(cfg, jobqueue.NewQueue(cfg.QueueSize), dummyProc{}, bo,
+		WithQueue(QueueSpec{Name: "emails", Queue: jobqueue.NewQueue(1), Processor: emails, Workers: 2, DeadLetters: deadLetters}))
+	acceptingMu, accepting := &sync.Mutex{}, boolPtr(true)
+	mux := a.buildMux(acceptingMu, accepting)
+	enqueue := func(path, id string) int {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"id":"`+id+`","payload":"p"}`)))
+		return rr.Code
+	}
+	if code := enqueue("/queues/emails/enqueue", "e1"); code != http.StatusAccepted {
+		t.Fatalf("expected named enqueue to be accepted, got %d", code)
+	}
+	if code := enqueue("/queues/emails/enqueue", "e2"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected named queue capacity to apply, got %d", code)
+	}
+	if code := enqueue("/enqueue", "d1"); code != http.StatusAccepted {
+		t.Fatalf("expected default queue unaffected by a full named queue, got %d", code)
+	}
+	if code := enqueue("/queues/default/enqueue", "d2"); code != http.StatusAccepted {
+		t.Fatalf("expected default queue under /queues, got %d", code)
+	}
+	if code := enqueue("/queues/reports/enqueue", "r1"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown queue, got %d", code)
+	}
+	for _, path := range []string{"/queues/emails/queues", "/queues/emails/queues/emails/queue", "/queues/emails/readyz"} {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 for root-only route under a named queue, got %d", path, rr.Code)
+		}
+	}
+
+	var wg sync.WaitGroup
+	a.startProcessing(&wg)
+	for _, nq := range a.queues {
+		nq.app.startProcessing(&nq.wg)
+	}
+	child := a.queues[0].app
+	if child.dlq != deadLetters || child.dlq == a.dlq {
+		t.Fatal("expected named queue to use its own dead-letter store")
+	}
+	if _, err := child.q.Wait(context.Background(), "e1"); err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/queues/emails/jobs/e1/result", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "sent e1" {
+		t.Fatalf("expected named queue processor result, got %d %q", rr.Code, rr.Body.String())
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/jobs/e1", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected named queue jobs to stay out of the default queue, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{`jobqueue_capacity{queue="emails"} 1`, `workers{queue="emails"} 2`,
+		`jobqueue_transitions_total{queue="emails",state="done"} 1`, `jobqueue_capacity{queue="default"} 8`} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, rr.Body.String())
+		}
+	}
+
+	var list []queueView
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/queues", nil))
+	_ = json.NewDecoder(rr.Body).Decode(&list)
+	if len(list) != 2 || list[0].Name != DefaultQueue || list[0].Capacity != 8 || list[0].Workers != 1 ||
+		list[1].Name != "emails" || list[1].Capacity != 1 || list[1].Workers != 2 {
+		t.Fatalf("unexpected queue list %+v", list)
+	}
+
+	rep := a.gracefulStop(&http.Server{}, acceptingMu, accepting, &wg)
+	if rep.TimedOut || rep.Dropped != 0 {
+		t.Fatalf("unexpected shutdown report %+v", rep)
+	}
+	if code := enqueue("/queues/emails/enqueue", "e3"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected named queue to stop accepting on shutdown, got %d", code)
+	}
 }
@@ -0,0 +1,259 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"kaspContainers/internal/dlq"
+	"kaspContainers/internal/jobqueue"
+)
+
+// attemptView — представление попытки обработки в ответах HTTP API.
+type attemptView struct {
+	Number     int       `json:"number"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	Code       string    `json:"code,omitempty"`
+	// Class и RetryAfterMs объясняют, почему после попытки задание повторялось или завершилось.
+	Class        string `json:"class,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+	Stack        string `json:"stack,omitempty"` // стек при панике процессора
+}
+
+// dlqEntryView — представление записи DLQ в ответах HTTP API.
+type dlqEntryView struct {
+	ID         string        `json:"id"`
+	Payload    string        `json:"payload"`
+	MaxRetries int           `json:"max_retries"`
+	LastError  string        `json:"last_error,omitempty"`
+	FailedAt   time.Time     `json:"failed_at"`
+	Attempts   []attemptView `json:"attempts"`
+}
+
+// dlqList — страница листинга DLQ.
+type dlqList struct {
+	Entries    []dlqEntryView `json:"entries"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// newAttemptViews формирует представление истории попыток.
+func newAttemptViews(history []jobqueue.Attempt) []attemptView {
+	out := make([]attemptView, 0, len(history))
+	for _, at := range history {
+		out = append(out, attemptView{
+			Number:       at.Number,
+			StartedAt:    at.StartedAt,
+			DurationMs:   at.Duration.Milliseconds(),
+			Error:        at.Error,
+			Code:         at.Code,
+			Class:        at.Class,
+			RetryAfterMs: at.RetryAfter.Milliseconds(),
+			Stack:        at.Stack,
+		})
+	}
+	return out
+}
+
+// newDLQEntryView формирует ответ API из записи DLQ.
+func newDLQEntryView(e dlq.Entry) dlqEntryView {
+	return dlqEntryView{
+		ID:         e.Job.ID,
+		Payload:    e.Job.Payload,
+		MaxRetries: e.Job.MaxRetries,
+		LastError:  e.LastError,
+		FailedAt:   e.FailedAt,
+		Attempts:   newAttemptViews(e.Attempts),
+	}
+}
+
+// parseDLQFilter собирает фильтр DLQ из параметров запроса.
+func parseDLQFilter(r *http.Request) (dlq.Filter, string) {
+	qv := r.URL.Query()
+	p, msg := parsePageParams(qv)
+	return dlq.Filter{
+		Prefix:        qv.Get("prefix"),
+		ErrorContains: qv.Get("error"),
+		From:          p.From,
+		To:            p.To,
+		After:         p.After,
+		Limit:         p.Limit,
+	}, msg
+}
+
+// handleDLQ обрабатывает /dlq: GET — постраничный листинг, DELETE — очистка
+// записей, подходящих под фильтр (без фильтра — всех).
+func (a *App) handleDLQ(w http.ResponseWriter, r *http.Request) {
+	f, msg := parseDLQFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		entries, next := a.dlq.List(f)
+		resp := dlqList{Entries: make([]dlqEntryView, 0, len(entries)), NextCursor: encodeCursor(next)}
+		for _, e := range entries {
+			resp.Entries = append(resp.Entries, newDLQEntryView(e))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodDelete:
+		n, err := a.dlq.Purge(f)
+		if err != nil {
+			a.reqLog(r).Error("dlq purge error", "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		a.reqLog(r).Info("dlq purged", "count", n)
+		writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDLQEntry обрабатывает /dlq/{id}: GET — просмотр записи, DELETE — удаление.
+func (a *App) handleDLQEntry(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	switch r.Method {
+	case http.MethodGet:
+		e, ok := a.dlq.Get(id)
+		if !ok {
+			http.Error(w, "dlq entry not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, newDLQEntryView(e))
+	case http.MethodDelete:
+		removed, err := a.dlq.Remove(id)
+		if err != nil {
+			a.reqLog(r).Error("dlq remove error", "job_id", id, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "dlq entry not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// errDLQNotFound возвращается redrive для отсутствующей записи DLQ.
+var errDLQNotFound = errors.New("dlq entry not found")
+
+// redrive возвращает задание из DLQ в основную очередь и удаляет запись из DLQ.
+func (a *App) redrive(log *slog.Logger, id string) error {
+	log = log.With("job_id", id)
+	e, ok := a.dlq.Get(id)
+	if !ok {
+		return errDLQNotFound
+	}
+	if err := a.q.Rerun(e.Job); err != nil {
+		return err
+	}
+	if _, err := a.dlq.Remove(id); err != nil {
+		log.Error("dlq remove after redrive error", "err", err)
+	}
+	log.Info("redriven", "state", jobqueue.StateQueued)
+	return nil
+}
+
+// writeRedriveError отвечает ошибкой redrive с подходящим кодом статуса.
+func writeRedriveError(w http.ResponseWriter, log *slog.Logger, id string, err error) {
+	switch {
+	case errors.Is(err, errDLQNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, jobqueue.ErrFull):
+		http.Error(w, "queue full", http.StatusTooManyRequests)
+	case errors.Is(err, jobqueue.ErrClosed):
+		http.Error(w, "queue closed", http.StatusServiceUnavailable)
+	case errors.Is(err, jobqueue.ErrDuplicate):
+		http.Error(w, "job is already active", http.StatusConflict)
+	default:
+		log.Error("redrive error", "job_id", id, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// handleDLQRedrive обрабатывает POST /dlq/{id}/redrive.
+func (a *App) handleDLQRedrive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	log := a.reqLog(r)
+	if err := a.redrive(log, id); err != nil {
+		writeRedriveError(w, log, id, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": string(jobqueue.StateQueued)})
+}
+
+// redriveFailure — запись DLQ, которую не удалось вернуть в очередь при пакетном redrive.
+type redriveFailure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// handleDLQRedriveBatch обрабатывает POST /dlq/redrive: возвращает в очередь
+// записи DLQ, подходящие под фильтр из тела запроса, в порядке попадания в DLQ.
+// Ошибка отдельной записи (например, задание ещё активно) попадает в failed, остальные записи
+// обрабатываются дальше. Переполнение или закрытие очереди прекращает redrive; оставшиеся записи остаются в DLQ.
+func (a *App) handleDLQRedriveBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Prefix        string    `json:"prefix"`
+		ErrorContains string    `json:"error_contains"`
+		From          time.Time `json:"from"`
+		To            time.Time `json:"to"`
+		Limit         int       `json:"limit"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Limit < 0 || req.Limit > maxListLimit {
+		http.Error(w, "limit must be between 0 and 1000", http.StatusBadRequest)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = maxListLimit
+	}
+	entries, _ := a.dlq.List(dlq.Filter{
+		Prefix:        req.Prefix,
+		ErrorContains: req.ErrorContains,
+		From:          req.From,
+		To:            req.To,
+		Limit:         req.Limit,
+	})
+	resp := struct {
+		Redriven  []string         `json:"redriven"`
+		Failed    []redriveFailure `json:"failed"`
+		Remaining int              `json:"remaining"`
+		Error     string           `json:"error,omitempty"`
+	}{Redriven: []string{}, Failed: []redriveFailure{}}
+	for i, e := range entries {
+		err := a.redrive(a.reqLog(r), e.Job.ID)
+		if errors.Is(err, jobqueue.ErrFull) || errors.Is(err, jobqueue.ErrClosed) {
+			resp.Remaining = len(entries) - i
+			resp.Error = err.Error()
+			break
+		}
+		if err != nil {
+			resp.Failed = append(resp.Failed, redriveFailure{ID: e.Job.ID, Error: err.Error()})
+			continue
+		}
+		resp.Redriven = append(resp.Redriven, e.Job.ID)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
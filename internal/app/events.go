@@ -0,0 +1,154 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"kaspContainers/internal/events"
+	"kaspContainers/internal/jobqueue"
+)
+
+// sseHeartbeat — период комментариев-пингов, не дающих прокси закрыть простаивающий поток.
+const sseHeartbeat = 15 * time.Second
+
+// streamEvent — тело события в потоке GET /events.
+type streamEvent struct {
+	Type      jobqueue.EventType `json:"type"`
+	JobID     string             `json:"job_id"`
+	State     jobqueue.State     `json:"state,omitempty"`
+	PrevState jobqueue.State     `json:"prev_state,omitempty"`
+	Priority  jobqueue.Priority  `json:"priority,omitempty"`
+	Attempts  int                `json:"attempts"`
+	Error     string             `json:"error,omitempty"`
+	RequestID string             `json:"request_id,omitempty"`
+	Time      time.Time          `json:"time"`
+}
+
+// publishEvent переводит событие очереди в событие потока. Вызывается наблюдателем под мьютексом очереди.
+func (a *App) publishEvent(ev jobqueue.Event) {
+	se := streamEvent{
+		Type:      ev.Type,
+		JobID:     ev.Job.ID,
+		State:     ev.Job.State,
+		Priority:  ev.Job.Priority,
+		Attempts:  ev.Job.Attempts,
+		RequestID: ev.Job.RequestID,
+		Time:      ev.Time,
+	}
+	switch ev.Type {
+	case jobqueue.EventRejected:
+		if ev.Err != nil {
+			se.Error = ev.Err.Error()
+		}
+	case jobqueue.EventTransition:
+		se.PrevState = ev.Prev
+		if ev.Job.State == jobqueue.StateFailed {
+			se.Error = ev.Job.LastError
+		}
+	}
+	data, err := json.Marshal(se)
+	if err != nil {
+		a.log.Error("events: marshal failed", "job_id", ev.Job.ID, "err", err)
+		return
+	}
+	a.events.Publish(events.Event{Type: string(se.Type), JobID: se.JobID, State: string(se.State), Data: data})
+}
+
+// parseEventFilter собирает фильтр потока из параметров job_id и state (повторяемых или через запятую).
+// Возвращает текст ошибки для ответа 400 или пустую строку.
+func parseEventFilter(r *http.Request) (func(events.Event) bool, string) {
+	qv := r.URL.Query()
+	split := func(key string) []string {
+		var out []string
+		for _, v := range qv[key] {
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					out = append(out, s)
+				}
+			}
+		}
+		return out
+	}
+	ids, states := split("job_id"), split("state")
+	for _, st := range states {
+		switch jobqueue.State(st) {
+		case jobqueue.StateQueued, jobqueue.StateScheduled, jobqueue.StateRunning,
+			jobqueue.StateDone, jobqueue.StateFailed, jobqueue.StateCancelled:
+		default:
+			return nil, "invalid state"
+		}
+	}
+	if len(ids) == 0 && len(states) == 0 {
+		return nil, ""
+	}
+	return func(ev events.Event) bool {
+		return (len(ids) == 0 || slices.Contains(ids, ev.JobID)) &&
+			(len(states) == 0 || slices.Contains(states, ev.State))
+	}, ""
+}
+
+// handleEvents обрабатывает GET /events: поток Server-Sent Events с событиями очереди.
+// Поток возобновляется с события после Last-Event-ID (заголовок или параметр last_event_id),
+// пока оно не вытеснено из буфера; иначе первым приходит событие gap.
+func (a *App) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	match, msg := parseEventFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after uint64
+	if lastID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub := a.events.Subscribe(after, match)
+	defer sub.Close()
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if sub.Gap {
+		fmt.Fprintf(w, "event: gap\ndata: {\"last_event_id\":%d}\n\n", after)
+	}
+	if err := rc.Flush(); err != nil {
+		a.reqLog(r).Error("events: streaming unsupported", "err", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
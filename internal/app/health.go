@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/health"
+)
+
+// defaultQueueHighWater — порог заполненности очереди (в процентах), выше которого сервис не готов.
+const defaultQueueHighWater = 90
+
+// WithLivenessCheck добавляет проверку в пробу GET /livez.
+func WithLivenessCheck(name string, c health.Checker) Option {
+	return func(a *App) { a.live.Register(name, c) }
+}
+
+// WithReadinessCheck добавляет проверку в пробу GET /readyz, например доступность внешнего хранилища.
+func WithReadinessCheck(name string, c health.Checker) Option {
+	return func(a *App) { a.ready.Register(name, c) }
+}
+
+// registerHealthChecks регистрирует встроенные проверки: liveness — очередь отвечает,
+// readiness — заполненность очереди, зависшие воркеры и хранилище очереди.
+func (a *App) registerHealthChecks() {
+	a.live.Register("queue", func(context.Context) error {
+		a.q.Len() // зависает при взаимной блокировке очереди; реестр ограничивает время проверки
+		return nil
+	})
+	a.ready.Register("queue", func(context.Context) error {
+		hw := a.cfg.ReadyQueueHighWater
+		if hw <= 0 {
+			hw = defaultQueueHighWater
+		}
+		n, c := a.q.Len(), a.q.Cap()
+		if c > 0 && n*100 >= c*hw {
+			return fmt.Errorf("queue at %d of %d, above high-water mark %d%%", n, c, hw)
+		}
+		return nil
+	})
+	a.ready.Register("workers", func(context.Context) error {
+		if n := a.watch.overdue(time.Now()); n > 0 && n >= a.Workers() {
+			return fmt.Errorf("all %d workers are stuck past their attempt deadline", n)
+		}
+		return nil
+	})
+	a.ready.Register("storage", func(context.Context) error {
+		if err := a.q.StorageErr(); err != nil {
+			return fmt.Errorf("queue storage: %w", err)
+		}
+		return nil
+	})
+}
+
+// registerAcceptingCheck добавляет в readiness проверку приёма заданий: при остановке проба
+// сразу не проходит, и балансировщик перестаёт направлять трафик на под.
+func (a *App) registerAcceptingCheck(acceptingMu *sync.Mutex, accepting *bool) {
+	a.ready.Register("accepting", func(context.Context) error {
+		acceptingMu.Lock()
+		defer acceptingMu.Unlock()
+		if !*accepting {
+			return fmt.Errorf("shutting down")
+		}
+		return nil
+	})
+}
+
+// checkView — результат проверки в подробном ответе пробы.
+type checkView struct {
+	Name       string        `json:"name"`
+	Status     health.Status `json:"status"`
+	Error      string        `json:"error,omitempty"`
+	DurationMs int64         `json:"duration_ms"`
+}
+
+// probeView — подробный ответ пробы (?verbose).
+type probeView struct {
+	Status health.Status `json:"status"`
+	Checks []checkView   `json:"checks"`
+}
+
+// probeHandler возвращает обработчик пробы по реестру reg: 200 при пройденных проверках, иначе 503.
+// С параметром verbose ответ — JSON со всеми проверками, без него — краткий текст.
+func (a *App) probeHandler(reg *health.Registry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rep := reg.Run(r.Context())
+		status := http.StatusOK
+		if rep.Status != health.StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		if r.URL.Query().Has("verbose") {
+			v := probeView{Status: rep.Status, Checks: make([]checkView, 0, len(rep.Checks))}
+			for _, c := range rep.Checks {
+				v.Checks = append(v.Checks, checkView{Name: c.Name, Status: c.Status, Error: c.Error, DurationMs: c.Duration.Milliseconds()})
+			}
+			writeJSON(w, status, v)
+			return
+		}
+		if status == http.StatusOK {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		var failed []string
+		for _, c := range rep.Checks {
+			if c.Status != health.StatusOK {
+				failed = append(failed, c.Name)
+			}
+		}
+		http.Error(w, "failed checks: "+strings.Join(failed, ", "), status)
+	}
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+)
+
+// jobStatus — представление состояния задания в ответах HTTP API.
+type jobStatus struct {
+	ID          string            `json:"id"`
+	State       jobqueue.State    `json:"state"`
+	Priority    jobqueue.Priority `json:"priority,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	RunAt       time.Time         `json:"run_at,omitzero"`
+	Attempts    int               `json:"attempts"`
+	EnqueuedAt  time.Time         `json:"enqueued_at,omitzero"`
+	StartedAt   time.Time         `json:"started_at,omitzero"`
+	FinishedAt  time.Time         `json:"finished_at,omitzero"`
+	LastError   string            `json:"last_error,omitempty"`
+	PayloadSize int               `json:"payload_size"`
+	RequestID   string            `json:"request_id,omitempty"`
+	// ResultSize и ResultExpiresAt описывают результат, доступный через GET /jobs/{id}/result.
+	ResultSize      int       `json:"result_size,omitempty"`
+	ResultExpiresAt time.Time `json:"result_expires_at,omitzero"`
+	// History — попытки обработки с кодом и классом ошибки: по ним видно, почему задание перестало повторяться.
+	History []attemptView `json:"history,omitempty"`
+}
+
+// newJobStatus формирует ответ API из сведений очереди о задании.
+func newJobStatus(ji jobqueue.JobInfo) jobStatus {
+	st := jobStatus{
+		ID:          ji.ID,
+		State:       ji.State,
+		Priority:    ji.Priority,
+		Type:        ji.Type,
+		RunAt:       ji.RunAt,
+		Attempts:    ji.Attempts,
+		EnqueuedAt:  ji.EnqueuedAt,
+		StartedAt:   ji.StartedAt,
+		FinishedAt:  ji.FinishedAt,
+		LastError:   ji.LastError,
+		PayloadSize: ji.PayloadSize,
+		RequestID:   ji.RequestID,
+	}
+	if len(ji.History) > 0 {
+		st.History = newAttemptViews(ji.History)
+	}
+	if ji.Result != nil {
+		st.ResultSize, st.ResultExpiresAt = len(ji.Result.Data), ji.Result.ExpiresAt
+	}
+	return st
+}
+
+// writeJSON сериализует v в ответ с заданным кодом статуса.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// jobList — страница листинга заданий.
+type jobList struct {
+	Jobs       []jobStatus `json:"jobs"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// encodeCursor кодирует позицию листинга в непрозрачную строку.
+func encodeCursor(seq uint64) string {
+	if seq == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+// decodeCursor разбирает курсор, полученный от encodeCursor.
+func decodeCursor(s string) (uint64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+// pageParams — общие параметры листингов: диапазон времени и позиция страницы.
+type pageParams struct {
+	From, To time.Time
+	Limit    int
+	After    uint64
+}
+
+// parsePageParams разбирает параметры from, to, limit и cursor.
+// Возвращает текст ошибки для ответа 400 или пустую строку.
+func parsePageParams(qv url.Values) (pageParams, string) {
+	p := pageParams{Limit: defaultListLimit}
+	var err error
+	if v := qv.Get("from"); v != "" {
+		if p.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return p, "from must be RFC3339"
+		}
+	}
+	if v := qv.Get("to"); v != "" {
+		if p.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return p, "to must be RFC3339"
+		}
+	}
+	if v := qv.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			return p, "limit must be between 1 and " + strconv.Itoa(maxListLimit)
+		}
+		p.Limit = n
+	}
+	if p.After, err = decodeCursor(qv.Get("cursor")); err != nil {
+		return p, "invalid cursor"
+	}
+	return p, ""
+}
+
+// parseListFilter собирает фильтр листинга из параметров запроса.
+func parseListFilter(r *http.Request) (jobqueue.ListFilter, string) {
+	qv := r.URL.Query()
+	f := jobqueue.ListFilter{Prefix: qv.Get("prefix")}
+	switch st := jobqueue.State(qv.Get("state")); st {
+	case "", jobqueue.StateQueued, jobqueue.StateScheduled, jobqueue.StateRunning,
+		jobqueue.StateDone, jobqueue.StateFailed, jobqueue.StateCancelled:
+		f.State = st
+	default:
+		return f, "invalid state"
+	}
+	p, msg := parsePageParams(qv)
+	f.From, f.To, f.Limit, f.After = p.From, p.To, p.Limit, p.After
+	return f, msg
+}
+
+// handleJobs обрабатывает GET /jobs: постраничный листинг заданий с фильтрами
+// по состоянию, префиксу идентификатора и времени постановки.
+func (a *App) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, msg := parseListFilter(r)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	infos, next, err := a.q.List(f)
+	if err != nil {
+		a.reqLog(r).Error("list jobs error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	resp := jobList{Jobs: make([]jobStatus, 0, len(infos)), NextCursor: encodeCursor(next)}
+	for _, ji := range infos {
+		resp.Jobs = append(resp.Jobs, newJobStatus(ji))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// queueStats — сводка о заполненности очереди по полосам приоритетов.
+type queueStats struct {
+	Depth    int                       `json:"depth"`
+	Capacity int                       `json:"capacity"`
+	Lanes    map[jobqueue.Priority]int `json:"lanes"`
+}
+
+// handleQueueStats обрабатывает GET /queue: глубина очереди по приоритетам.
+func (a *App) handleQueueStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, queueStats{Depth: a.q.Len(), Capacity: a.q.Cap(), Lanes: a.q.Depths()})
+}
+
+// handleJob обрабатывает /jobs/{id}: GET возвращает состояние задания, DELETE отменяет его.
+func (a *App) handleJob(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.getJob(w, r)
+	case http.MethodDelete:
+		a.cancelJob(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// cancelJob отменяет задание: ожидающее — сразу (200), выполняющемуся
+// отправляется сигнал отмены (202). Завершённое задание отменить нельзя (409).
+func (a *App) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	log := a.reqLog(r).With("job_id", id)
+	prev, err := a.q.Cancel(id)
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		http.Error(w, "job not found", http.StatusNotFound)
+	case errors.Is(err, jobqueue.ErrFinished):
+		http.Error(w, "job already "+string(prev), http.StatusConflict)
+	case err != nil:
+		log.Error("cancel job error", "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	case prev == jobqueue.StateRunning:
+		log.Info("cancel requested", "state", prev)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling"})
+	default:
+		log.Info("cancelled", "state", jobqueue.StateCancelled)
+		writeJSON(w, http.StatusOK, map[string]string{"status": string(jobqueue.StateCancelled)})
+	}
+}
+
+// getJob возвращает состояние задания или 404.
+func (a *App) getJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ji, err := a.q.Get(id)
+	if errors.Is(err, jobqueue.ErrNotFound) {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.reqLog(r).Error("get job error", "job_id", id, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newJobStatus(ji))
+}
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// handleJobWait обрабатывает GET /jobs/{id}/wait?timeout=30s: ждёт завершения задания
+// (done, failed или cancelled) и возвращает его состояние (200). Если за timeout задание
+// не завершилось или сервис останавливается, возвращает текущее состояние с кодом 202.
+func (a *App) handleJobWait(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	timeout := defaultWaitTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > maxWaitTimeout {
+			http.Error(w, "timeout must be a duration between 0s and "+maxWaitTimeout.String(), http.StatusBadRequest)
+			return
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	stop := context.AfterFunc(a.stopCtx, cancel)
+	defer stop()
+
+	id := r.PathValue("id")
+	ji, err := a.q.Wait(ctx, id)
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		http.Error(w, "job not found", http.StatusNotFound)
+	case r.Context().Err() != nil:
+		// клиент отключился — отвечать некому
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		writeJSON(w, http.StatusAccepted, newJobStatus(ji))
+	case err != nil:
+		a.reqLog(r).Error("wait job error", "job_id", id, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, newJobStatus(ji))
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader — заголовок, в котором клиент может передать идентификатор запроса.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// newRequestID генерирует случайный идентификатор запроса.
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID допускает идентификаторы до 128 видимых ASCII-символов.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDFrom возвращает идентификатор запроса из контекста или пустую строку.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID берёт идентификатор запроса из X-Request-ID (или генерирует новый),
+// кладёт его в контекст и возвращает клиенту в том же заголовке.
+func (a *App) withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		start := time.Now()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		a.log.Debug("http request", "request_id", id, "method", r.Method, "path", r.URL.Path,
+			"duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// reqLog возвращает логгер с идентификатором запроса r.
+func (a *App) reqLog(r *http.Request) *slog.Logger {
+	if id := requestIDFrom(r.Context()); id != "" {
+		return a.log.With("request_id", id)
+	}
+	return a.log
+}
+
+// jobLog возвращает логгер с атрибутами задания: job_id и request_id запроса, который его поставил.
+func (a *App) jobLog(id, requestID string) *slog.Logger {
+	l := a.log.With("job_id", id)
+	if requestID != "" {
+		l = l.With("request_id", requestID)
+	}
+	return l
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/metrics"
+)
+
+// latencyBuckets — границы гистограммы полного времени жизни задания, в секундах.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+// appMetrics — метрики очереди App. Основная и именованные очереди пишут в общий metricSet,
+// серии различаются меткой queue.
+type appMetrics struct {
+	*metricSet
+	queue string // значение метки queue: DefaultQueue или имя именованной очереди
+}
+
+// metricSet — метрики сервиса, публикуемые на GET /metrics. Первая метка каждой метрики — queue.
+type metricSet struct {
+	reg *metrics.Registry
+
+	enqueues    *metrics.Counter   // исходы постановки: accepted, full, closed, duplicate, error
+	transitions *metrics.Counter   // переходы в состояние
+	depth       *metrics.Gauge     // ожидающие задания по приоритетам
+	paused      *metrics.Gauge     // 1 — выдача заданий полосы приостановлена
+	capacity    *metrics.Gauge     // ёмкость очереди
+	workers     *metrics.Gauge     // размер пула воркеров
+	busy        *metrics.Gauge     // воркеры, обрабатывающие задание
+	attempts    *metrics.Counter   // попытки по результату: success, failure, cancelled
+	attemptDur  *metrics.Histogram // длительность попытки, измеренная воркером
+	backoff     *metrics.Histogram // задержки перед повтором
+	timeouts    *metrics.Counter   // попытки, превысившие таймаут
+	stuck       *metrics.Gauge     // брошенные попытки, процессор которых ещё не вернулся
+	panics      *metrics.Counter   // перехваченные паники: processor — в попытке, worker — вне попытки
+	latency     *metrics.Histogram // от постановки до завершения, по итоговому состоянию
+	dlqEntries  *metrics.Gauge     // записи в DLQ
+}
+
+// newMetricSet регистрирует метрики сервиса в новом реестре.
+func newMetricSet() *metricSet {
+	reg := metrics.NewRegistry()
+	return &metricSet{
+		reg:         reg,
+		enqueues:    reg.NewCounter("jobqueue_enqueue_total", "Enqueue attempts by outcome.", "queue", "result"),
+		transitions: reg.NewCounter("jobqueue_transitions_total", "Job state transitions by target state.", "queue", "state"),
+		depth:       reg.NewGauge("jobqueue_depth", "Jobs waiting in the queue by priority.", "queue", "priority"),
+		paused:      reg.NewGauge("jobqueue_paused", "Whether handing out jobs of a priority lane is paused.", "queue", "priority"),
+		capacity:    reg.NewGauge("jobqueue_capacity", "Queue capacity.", "queue"),
+		workers:     reg.NewGauge("workers", "Worker pool size.", "queue"),
+		busy:        reg.NewGauge("workers_busy", "Workers currently processing a job.", "queue"),
+		attempts:    reg.NewCounter("job_attempts_total", "Processing attempts by result.", "queue", "result"),
+		attemptDur:  reg.NewHistogram("job_attempt_duration_seconds", "Processing attempt duration.", nil, "queue", "result"),
+		backoff:     reg.NewHistogram("job_backoff_delay_seconds", "Backoff delay before a retry.", nil, "queue"),
+		timeouts:    reg.NewCounter("job_attempt_timeouts_total", "Processing attempts that exceeded their timeout.", "queue"),
+		stuck:       reg.NewGauge("workers_stuck_attempts", "Abandoned attempts whose processor has not returned yet.", "queue"),
+		panics:      reg.NewCounter("worker_panics_total", "Recovered panics by source.", "queue", "source"),
+		latency:     reg.NewHistogram("job_latency_seconds", "Time from enqueue to a terminal state for processed jobs.", latencyBuckets, "queue", "state"),
+		dlqEntries:  reg.NewGauge("dlq_entries", "Jobs in the dead-letter queue.", "queue"),
+	}
+}
+
+// withMetrics подключает именованную очередь к метрикам основной: серии пишутся в set с меткой queue.
+func withMetrics(set *metricSet, queue string) Option {
+	return func(a *App) { a.metrics = &appMetrics{metricSet: set, queue: queue} }
+}
+
+// collect снимает состояние очереди и DLQ a при каждом запросе метрик.
+func (m *appMetrics) collect(a *App) {
+	m.reg.OnScrape(func() {
+		for p, n := range a.q.Depths() {
+			m.depth.Set(float64(n), m.queue, string(p))
+		}
+		ps := a.q.Paused()
+		for _, p := range jobqueue.Priorities {
+			v := 0.0
+			if ps.All || slices.Contains(ps.Lanes, p) {
+				v = 1
+			}
+			m.paused.Set(v, m.queue, string(p))
+		}
+		m.capacity.Set(float64(a.q.Cap()), m.queue)
+		m.dlqEntries.Set(float64(a.dlq.Len()), m.queue)
+		m.stuck.Set(float64(a.watch.stuckCount()), m.queue)
+	})
+}
+
+// observe учитывает события очереди. Вызывается под мьютексом очереди.
+func (m *appMetrics) observe(ev jobqueue.Event) {
+	switch ev.Type {
+	case jobqueue.EventEnqueued:
+		m.enqueues.Inc(m.queue, "accepted")
+	case jobqueue.EventRejected:
+		m.enqueues.Inc(m.queue, enqueueResult(ev.Err))
+	case jobqueue.EventTransition:
+		ji := ev.Job
+		m.transitions.Inc(m.queue, string(ji.State))
+		// Отклонённые и отменённые до запуска задания не искажают латентность.
+		if ji.State.Terminal() && !ji.StartedAt.IsZero() {
+			m.latency.Observe(ji.FinishedAt.Sub(ji.EnqueuedAt).Seconds(), m.queue, string(ji.State))
+		}
+	}
+}
+
+// enqueueResult возвращает значение метки result для отказа в постановке.
+func enqueueResult(err error) string {
+	switch {
+	case errors.Is(err, jobqueue.ErrFull):
+		return "full"
+	case errors.Is(err, jobqueue.ErrClosed):
+		return "closed"
+	case errors.Is(err, jobqueue.ErrDuplicate):
+		return "duplicate"
+	}
+	return "error"
+}
+
+// observeAttempt учитывает попытку обработки и её длительность.
+func (m *appMetrics) observeAttempt(result string, d time.Duration) {
+	m.attempts.Inc(m.queue, result)
+	m.attemptDur.Observe(d.Seconds(), m.queue, result)
+}
+
+// handleMetrics обрабатывает GET /metrics в текстовом формате Prometheus: метрики всех очередей.
+func (a *App) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := a.metrics.reg.Write(w); err != nil {
+		a.reqLog(r).Error("metrics write error", "err", err)
+	}
+}
@@ -0,0 +1,91 @@
+package app
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/processing"
+)
+
+// maxPanicStack — сколько байт стека паники сохраняется в истории попыток.
+const maxPanicStack = 8 << 10
+
+// panicError — паника, перехваченная при обработке задания, вместе со стеком горутины.
+type panicError struct {
+	value any
+	stack string
+}
+
+func (e *panicError) Error() string { return fmt.Sprintf("panic: %v", e.value) }
+
+// newPanicError фиксирует значение паники и стек. Вызывается из отложенной функции с recover.
+func newPanicError(v any) *processing.Error {
+	stack := debug.Stack()
+	if len(stack) > maxPanicStack {
+		stack = stack[:maxPanicStack]
+	}
+	pe := &panicError{value: v, stack: string(stack)}
+	return &processing.Error{Code: "panic", Message: pe.Error(), Err: pe}
+}
+
+// startWorker запускает воркер в слоте s; воркер завершается при закрытии очереди или отмене слота.
+// Паника, вышедшая за пределы попытки, завершает текущее задание неуспехом и перезапускает слот,
+// чтобы пул не уменьшался.
+func (a *App) startWorker(wg *sync.WaitGroup, s *workerSlot) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var current jobqueue.Job
+		defer func() {
+			if v := recover(); v != nil {
+				a.recoverWorker(s.id, current, newPanicError(v))
+				a.startWorker(wg, s)
+				return
+			}
+			a.pool.exited(s)
+		}()
+		for {
+			job, ok := a.q.NextContext(s.ctx)
+			if !ok {
+				return
+			}
+			current = job
+			a.processJob(s.id, job)
+			current = jobqueue.Job{}
+		}
+	}()
+}
+
+// recoverWorker учитывает панику воркера: записывает её в историю попыток задания,
+// которое он выполнял, и переводит задание в failed.
+func (a *App) recoverWorker(worker int, job jobqueue.Job, perr *processing.Error) {
+	a.metrics.panics.Inc(a.metrics.queue, "worker")
+	a.watch.panicked(worker, true)
+	pe := perr.Err.(*panicError)
+	log := a.log.With("worker", worker)
+	if job.ID == "" {
+		log.Error("worker panic", "err", perr, "stack", pe.stack)
+		return
+	}
+	log = a.jobLog(job.ID, job.RequestID).With("worker", worker)
+	log.Error("worker panic", "err", perr, "stack", pe.stack)
+	a.watch.end(worker)
+	ji, err := a.q.Get(job.ID)
+	if err != nil || ji.State != jobqueue.StateRunning {
+		return
+	}
+	a.q.UpdatesAttempt(job.ID, jobqueue.Attempt{
+		StartedAt: time.Now(),
+		Error:     perr.Error(),
+		Code:      perr.Code,
+		Class:     string(processing.ClassPermanent),
+		Stack:     pe.stack,
+	})
+	a.q.UpdatesStateFailed(job.ID)
+	a.deadLetter(log, job)
+	a.notify(log, job)
+	log.Warn("failed", "state", jobqueue.StateFailed, "code", perr.Code)
+}
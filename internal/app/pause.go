@@ -0,0 +1,106 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"kaspContainers/internal/jobqueue"
+)
+
+// maxJobTypeLen — максимальная длина вида задания (поле type).
+const maxJobTypeLen = 64
+
+// pauseTarget — тело POST /admin/pause и /admin/resume: полоса приоритета или вид задания.
+// Пустое тело относится ко всей выдаче заданий.
+type pauseTarget struct {
+	Priority string `json:"priority"`
+	Type     string `json:"type"`
+}
+
+// pauseView — текущие паузы в ответах /admin/pause и /admin/resume.
+type pauseView struct {
+	Paused     bool                `json:"paused"`
+	Priorities []jobqueue.Priority `json:"priorities"`
+	Types      []string            `json:"types"`
+}
+
+// newPauseView снимает текущие паузы очереди.
+func (a *App) newPauseView() pauseView {
+	st := a.q.Paused()
+	return pauseView{Paused: st.All, Priorities: st.Lanes, Types: st.Types}
+}
+
+// handleAdminPause обрабатывает GET /admin/pause (текущие паузы) и POST /admin/pause —
+// приостановку выдачи заданий воркерам. Постановка в очередь при этом продолжается.
+func (a *App) handleAdminPause(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.newPauseView())
+	case http.MethodPost:
+		a.applyPause(w, r, true)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminResume обрабатывает POST /admin/resume — снятие паузы, установленной через /admin/pause.
+func (a *App) handleAdminResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a.applyPause(w, r, false)
+}
+
+// applyPause ставит (pause=true) или снимает паузу для цели из тела запроса.
+func (a *App) applyPause(w http.ResponseWriter, r *http.Request, pause bool) {
+	var t pauseTarget
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if t.Priority != "" && t.Type != "" {
+		http.Error(w, "priority and type are mutually exclusive", http.StatusBadRequest)
+		return
+	}
+	if len(t.Type) > maxJobTypeLen {
+		http.Error(w, "type too long", http.StatusBadRequest)
+		return
+	}
+	action := "resumed"
+	if pause {
+		action = "paused"
+	}
+	log := a.reqLog(r)
+	switch {
+	case t.Priority != "":
+		p, err := jobqueue.ParsePriority(t.Priority)
+		if err != nil {
+			http.Error(w, "priority must be one of high, normal, low", http.StatusBadRequest)
+			return
+		}
+		if pause {
+			a.q.PauseLane(p)
+		} else {
+			a.q.ResumeLane(p)
+		}
+		log.Info("processing "+action, "priority", p)
+	case t.Type != "":
+		if pause {
+			a.q.PauseType(t.Type)
+		} else {
+			a.q.ResumeType(t.Type)
+		}
+		log.Info("processing "+action, "type", t.Type)
+	default:
+		if pause {
+			a.q.Pause()
+		} else {
+			a.q.Resume()
+		}
+		log.Info("processing " + action)
+	}
+	writeJSON(w, http.StatusOK, a.newPauseView())
+}
@@ -0,0 +1,299 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultWorkersMax — верхняя граница размера пула, если она не задана в конфигурации.
+	defaultWorkersMax = 64
+	// defaultAutoscaleInterval — период решений автоскейлера.
+	defaultAutoscaleInterval = 5 * time.Second
+	// defaultAutoscaleTargetWait — за сколько автоскейлер стремится разобрать очередь.
+	defaultAutoscaleTargetWait = time.Second
+	// attemptEWMAWeight — вес новой попытки в скользящей средней длительности попыток.
+	attemptEWMAWeight = 0.2
+)
+
+var (
+	// ErrPoolNotStarted — пул воркеров ещё не запущен.
+	ErrPoolNotStarted = errors.New("worker pool not started")
+	// ErrPoolClosed — пул воркеров остановлен: сервис завершает работу.
+	ErrPoolClosed = errors.New("worker pool closed")
+	// ErrPoolSize — размер пула вне допустимых границ.
+	ErrPoolSize = errors.New("worker pool size out of range")
+)
+
+// workerSlot — слот пула. Отмена ctx останавливает воркер слота после текущего задания.
+type workerSlot struct {
+	id       int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping bool // слот убран из пула и ждёт завершения текущего задания
+}
+
+// workerPool — набор слотов воркеров, размер которого меняется на ходу.
+type workerPool struct {
+	mu      sync.Mutex
+	wg      *sync.WaitGroup // nil — пул не запущен
+	slots   map[int]*workerSlot
+	size    int // слоты без stopping
+	closed  bool
+	done    chan struct{} // закрывается при остановке пула
+	busy    int           // воркеры, обрабатывающие задание
+	avgTime time.Duration // скользящая средняя длительности попытки
+}
+
+func newWorkerPool() *workerPool {
+	return &workerPool{slots: make(map[int]*workerSlot), done: make(chan struct{})}
+}
+
+// observeAttempt учитывает длительность попытки в скользящей средней.
+func (p *workerPool) observeAttempt(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.avgTime == 0 {
+		p.avgTime = d
+		return
+	}
+	p.avgTime += time.Duration(attemptEWMAWeight * float64(d-p.avgTime))
+}
+
+// addBusy меняет число занятых воркеров на delta.
+func (p *workerPool) addBusy(delta int) {
+	p.mu.Lock()
+	p.busy += delta
+	p.mu.Unlock()
+}
+
+// exited снимает остановленный слот, когда его воркер завершился. Слоты, завершившиеся
+// из-за закрытия очереди, остаются в пуле для диагностики.
+func (p *workerPool) exited(s *workerSlot) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s.stopping && p.slots[s.id] == s {
+		delete(p.slots, s.id)
+	}
+}
+
+// close останавливает пул: размер больше не меняется, автоскейлер завершается.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+}
+
+// slotIDs возвращает идентификаторы слотов по возрастанию и признак остановки каждого.
+func (p *workerPool) slotIDs() (ids []int, stopping map[int]bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stopping = make(map[int]bool)
+	for id, s := range p.slots {
+		ids = append(ids, id)
+		if s.stopping {
+			stopping[id] = true
+		}
+	}
+	slices.Sort(ids)
+	return ids, stopping
+}
+
+// Workers возвращает текущий размер пула воркеров.
+func (a *App) Workers() int {
+	a.pool.mu.Lock()
+	defer a.pool.mu.Unlock()
+	return a.pool.size
+}
+
+// workersMax возвращает верхнюю границу размера пула; не меньше начального WORKERS.
+func (a *App) workersMax() int {
+	n := a.cfg.WorkersMax
+	if n <= 0 {
+		n = defaultWorkersMax
+	}
+	return max(n, a.cfg.Workers)
+}
+
+// workersMin возвращает нижнюю границу размера пула для автоскейлера.
+func (a *App) workersMin() int {
+	return min(max(a.cfg.WorkersMin, 1), a.workersMax())
+}
+
+// ResizeWorkers меняет размер пула воркеров на n и возвращает прежний размер. Новые воркеры
+// сразу начинают брать задания; убранные дорабатывают текущее задание и завершаются.
+func (a *App) ResizeWorkers(n int) (int, error) {
+	p := a.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.closed:
+		return p.size, ErrPoolClosed
+	case p.wg == nil:
+		return p.size, ErrPoolNotStarted
+	case n < 1 || n > a.workersMax():
+		return p.size, fmt.Errorf("%w: %d not in [1, %d]", ErrPoolSize, n, a.workersMax())
+	}
+	prev := p.size
+	a.resizeLocked(n)
+	return prev, nil
+}
+
+// resizeLocked доводит число активных слотов до n: новые слоты получают наименьшие свободные номера,
+// останавливаются слоты с наибольшими номерами. Вызывается под p.mu.
+func (a *App) resizeLocked(n int) {
+	p := a.pool
+	for id := 0; p.size < n; id++ {
+		if _, used := p.slots[id]; used {
+			continue
+		}
+		ctx, cancel := context.WithCancel(a.workCtx)
+		s := &workerSlot{id: id, ctx: ctx, cancel: cancel}
+		p.slots[id] = s
+		p.size++
+		a.startWorker(p.wg, s)
+	}
+	if p.size > n {
+		var active []int
+		for id, s := range p.slots {
+			if !s.stopping {
+				active = append(active, id)
+			}
+		}
+		slices.Sort(active)
+		for _, id := range active[n:] {
+			s := p.slots[id]
+			s.stopping = true
+			s.cancel()
+			p.size--
+		}
+	}
+	a.metrics.workers.Set(float64(p.size), a.metrics.queue)
+}
+
+// autoscaleTarget вычисляет размер пула по загрузке: при очереди — столько воркеров, чтобы разобрать её
+// за targetWait при средней длительности попытки avg; без очереди — на один меньше, но не меньше занятых.
+// Результат ограничен [lo, hi].
+func autoscaleTarget(size, busy, depth int, avg, targetWait time.Duration, lo, hi int) int {
+	target := size
+	switch {
+	case depth > 0:
+		need := busy + depth
+		if avg > 0 && targetWait > 0 {
+			need = busy + int(math.Ceil(float64(depth)*float64(avg)/float64(targetWait)))
+		}
+		target = max(size, need)
+	case busy < size:
+		target = max(size-1, busy)
+	}
+	return min(max(target, lo), hi)
+}
+
+// autoscale периодически подстраивает размер пула под глубину очереди и длительность попыток
+// в границах WORKERS_MIN..WORKERS_MAX, пока пул не остановлен.
+func (a *App) autoscale() {
+	interval := defaultAutoscaleInterval
+	if a.cfg.AutoscaleIntervalMs > 0 {
+		interval = time.Duration(a.cfg.AutoscaleIntervalMs) * time.Millisecond
+	}
+	targetWait := defaultAutoscaleTargetWait
+	if a.cfg.AutoscaleTargetWaitMs > 0 {
+		targetWait = time.Duration(a.cfg.AutoscaleTargetWaitMs) * time.Millisecond
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.pool.done:
+			return
+		case <-t.C:
+		}
+		depth := a.q.Available() // задания, задержанные паузой, не требуют воркеров
+		p := a.pool
+		p.mu.Lock()
+		if p.closed || p.wg == nil {
+			p.mu.Unlock()
+			continue
+		}
+		size, busy, avg := p.size, p.busy, p.avgTime
+		target := autoscaleTarget(size, busy, depth, avg, targetWait, a.workersMin(), a.workersMax())
+		if target != size {
+			a.resizeLocked(target)
+		}
+		p.mu.Unlock()
+		if target != size {
+			a.log.Info("workers autoscaled", "from", size, "to", target, "depth", depth, "busy", busy, "avg_attempt_ms", avg.Milliseconds())
+		}
+	}
+}
+
+// poolView — состояние пула воркеров в ответах GET и PUT /admin/workers.
+type poolView struct {
+	Size         int   `json:"size"`
+	Busy         int   `json:"busy"`
+	Stopping     int   `json:"stopping"` // убранные воркеры, дорабатывающие текущее задание
+	Min          int   `json:"min"`
+	Max          int   `json:"max"`
+	Autoscale    bool  `json:"autoscale"`
+	AvgAttemptMs int64 `json:"avg_attempt_ms"`
+}
+
+// newPoolView снимает состояние пула.
+func (a *App) newPoolView() poolView {
+	p := a.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v := poolView{
+		Size:         p.size,
+		Busy:         p.busy,
+		Min:          a.workersMin(),
+		Max:          a.workersMax(),
+		Autoscale:    a.cfg.Autoscale,
+		AvgAttemptMs: p.avgTime.Milliseconds(),
+	}
+	for _, s := range p.slots {
+		if s.stopping {
+			v.Stopping++
+		}
+	}
+	return v
+}
+
+// handleAdminWorkers обрабатывает GET /admin/workers (состояние пула) и PUT /admin/workers
+// с телом {"size": n} — изменение размера пула на ходу.
+func (a *App) handleAdminWorkers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, a.newPoolView())
+	case http.MethodPut:
+		var req struct {
+			Size int `json:"size"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		prev, err := a.ResizeWorkers(req.Size)
+		switch {
+		case errors.Is(err, ErrPoolSize):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			a.reqLog(r).Info("workers resized", "from", prev, "to", req.Size)
+			writeJSON(w, http.StatusOK, a.newPoolView())
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,158 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"kaspContainers/internal/backoff"
+	"kaspContainers/internal/config"
+	"kaspContainers/internal/cron"
+	"kaspContainers/internal/dlq"
+	"kaspContainers/internal/health"
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/processing"
+)
+
+// DefaultQueue — имя основной очереди, принимающей задания через POST /enqueue.
+const DefaultQueue = "default"
+
+// QueueSpec описывает именованную очередь: свою ёмкость (размер буфера Queue), пул воркеров,
+// политику бэкоффа, процессор, DLQ и расписания. Задания ставятся через POST /queues/{name}/enqueue.
+type QueueSpec struct {
+	Name        string
+	Queue       *jobqueue.Queue
+	Processor   processing.Processor
+	Backoff     backoff.Policy  // nil — политика основной очереди
+	Workers     int             // 0 — WORKERS
+	DeadLetters *dlq.Store      // nil — DLQ в памяти
+	Schedules   *cron.Scheduler // nil — расписания в памяти; планировщик ставит задания в Queue
+}
+
+// namedQueue — именованная очередь со своим экземпляром App. Маршруты App доступны
+// под префиксом /queues/{name}, уведомления и трассировка общие с основной очередью.
+type namedQueue struct {
+	name string
+	app  *App
+	wg   sync.WaitGroup // воркеры очереди
+}
+
+// WithQueue добавляет именованную очередь; очередь с тем же именем заменяется.
+func WithQueue(spec QueueSpec) Option {
+	return func(a *App) {
+		a.specs = slices.DeleteFunc(a.specs, func(s QueueSpec) bool { return s.Name == spec.Name })
+		a.specs = append(a.specs, spec)
+	}
+}
+
+// newNamedQueues создаёт экземпляры App именованных очередей. Вызывается из New после опций,
+// чтобы очереди получили итоговые логгер, трассировщик, диспетчер уведомлений и метрики.
+func (a *App) newNamedQueues() {
+	for _, spec := range a.specs {
+		cfg := a.cfg
+		if spec.Workers > 0 {
+			cfg.Workers = spec.Workers
+		}
+		cfg.CheckpointPath = config.QueuePath(a.cfg.CheckpointPath, spec.Name)
+		bo := spec.Backoff
+		if bo == nil {
+			bo = a.bo
+		}
+		opts := []Option{WithLogger(a.log.With("queue", spec.Name)), WithTracer(a.tracer), WithWebhooks(a.hooks),
+			withMetrics(a.metrics.metricSet, spec.Name)}
+		if spec.DeadLetters != nil {
+			opts = append(opts, WithDeadLetters(spec.DeadLetters))
+		}
+		if spec.Schedules != nil {
+			opts = append(opts, WithSchedules(spec.Schedules))
+		}
+		child := New(cfg, spec.Queue, spec.Processor, bo, opts...)
+		a.queues = append(a.queues, &namedQueue{name: spec.Name, app: child})
+		a.ready.Register("queue:"+spec.Name, func(ctx context.Context) error {
+			rep := child.ready.Run(ctx)
+			if rep.Status == health.StatusOK {
+				return nil
+			}
+			var failed []string
+			for _, c := range rep.Checks {
+				if c.Status != health.StatusOK {
+					failed = append(failed, c.Name+": "+c.Error)
+				}
+			}
+			return fmt.Errorf("%s", strings.Join(failed, "; "))
+		})
+	}
+	slices.SortFunc(a.queues, func(x, y *namedQueue) int { return strings.Compare(x.name, y.name) })
+}
+
+// apps возвращает экземпляры App основной и всех именованных очередей.
+func (a *App) apps() []*App {
+	out := []*App{a}
+	for _, nq := range a.queues {
+		out = append(out, nq.app)
+	}
+	return out
+}
+
+// queueView — сводка очереди в ответе GET /queues.
+type queueView struct {
+	Name     string                    `json:"name"`
+	Depth    int                       `json:"depth"`
+	Capacity int                       `json:"capacity"`
+	Lanes    map[jobqueue.Priority]int `json:"lanes"`
+	Workers  int                       `json:"workers"`
+	Busy     int                       `json:"busy"`
+	Paused   bool                      `json:"paused"`
+}
+
+// newQueueView снимает сводку очереди name.
+func (a *App) newQueueView(name string) queueView {
+	pv := a.newPoolView()
+	return queueView{
+		Name:     name,
+		Depth:    a.q.Len(),
+		Capacity: a.q.Cap(),
+		Lanes:    a.q.Depths(),
+		Workers:  pv.Size,
+		Busy:     pv.Busy,
+		Paused:   a.q.Paused().All,
+	}
+}
+
+// handleQueues обрабатывает GET /queues: основная и именованные очереди по имени.
+func (a *App) handleQueues(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	out := []queueView{a.newQueueView(DefaultQueue)}
+	for _, nq := range a.queues {
+		out = append(out, nq.app.newQueueView(nq.name))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// queueHandler возвращает обработчик /queues/{name}/...: запрос передаётся маршрутам очереди name
+// без префикса, например POST /queues/emails/enqueue — в POST /enqueue очереди emails.
+// Имя default соответствует основной очереди с маршрутами mux. Именованным очередям доступны
+// только маршруты queueRoutes: служебные, уведомления и /queues есть лишь у основного App.
+func (a *App) queueHandler(mux http.Handler, acceptingMu *sync.Mutex, accepting *bool) http.HandlerFunc {
+	handlers := map[string]http.Handler{DefaultQueue: mux}
+	for _, nq := range a.queues {
+		qmux := http.NewServeMux()
+		nq.app.queueRoutes(qmux, acceptingMu, accepting)
+		handlers[nq.name] = qmux
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		h, ok := handlers[name]
+		if !ok {
+			http.Error(w, "queue not found", http.StatusNotFound)
+			return
+		}
+		http.StripPrefix("/queues/"+name, h).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,95 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/processing"
+)
+
+const (
+	// defaultResultMaxBytes — предел размера результата, если он не задан в конфигурации.
+	defaultResultMaxBytes = 1 << 20
+	// resultSweepInterval — период удаления результатов с истёкшим сроком хранения.
+	resultSweepInterval = time.Minute
+)
+
+// resultMaxBytes возвращает предел размера результата задания.
+func (a *App) resultMaxBytes() int {
+	if a.cfg.ResultMaxBytes > 0 {
+		return a.cfg.ResultMaxBytes
+	}
+	return defaultResultMaxBytes
+}
+
+// newResult формирует хранимый результат со сроком хранения из конфигурации.
+func (a *App) newResult(res processing.Result) jobqueue.Result {
+	r := jobqueue.Result{Data: res.Data, ContentType: res.ContentType}
+	if a.cfg.ResultTTLSeconds > 0 {
+		r.ExpiresAt = time.Now().Add(time.Duration(a.cfg.ResultTTLSeconds) * time.Second)
+	}
+	return r
+}
+
+// expireResults периодически удаляет результаты с истёкшим сроком хранения до остановки приложения.
+func (a *App) expireResults() {
+	t := time.NewTicker(resultSweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-a.stopCtx.Done():
+			return
+		case now := <-t.C:
+			if n := a.q.ExpireResults(now); n > 0 {
+				a.log.Debug("results expired", "count", n)
+			}
+		}
+	}
+}
+
+// handleJobResult обрабатывает GET /jobs/{id}/result: возвращает результат обработки задания
+// как есть, с его Content-Type. Для незавершённого задания — 409, для завершённого без результата — 204,
+// для результата с истёкшим сроком хранения — 410.
+func (a *App) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := r.PathValue("id")
+	ji, err := a.q.Get(id)
+	switch {
+	case errors.Is(err, jobqueue.ErrNotFound):
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	case err != nil:
+		a.reqLog(r).Error("get job error", "job_id", id, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	case ji.State != jobqueue.StateDone:
+		http.Error(w, "job is "+string(ji.State), http.StatusConflict)
+		return
+	}
+	res := ji.Result
+	if res != nil && res.Expired(time.Now()) {
+		http.Error(w, "result expired", http.StatusGone)
+		return
+	}
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	ct := res.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Content-Length", strconv.Itoa(len(res.Data)))
+	if !res.ExpiresAt.IsZero() {
+		w.Header().Set("Expires", res.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(res.Data))
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"kaspContainers/internal/cron"
+	"kaspContainers/internal/jobqueue"
+)
+
+// scheduleRequest — тело запроса создания или изменения расписания.
+type scheduleRequest struct {
+	Name       string `json:"name"`
+	Spec       string `json:"spec"`
+	Payload    string `json:"payload"`
+	MaxRetries int    `json:"max_retries"`
+	Priority   string `json:"priority"`
+	Overlap    string `json:"overlap"`
+	CatchUp    string `json:"catch_up"`
+}
+
+// scheduleView — представление расписания в ответах HTTP API.
+type scheduleView struct {
+	Name       string             `json:"name"`
+	Spec       string             `json:"spec"`
+	Payload    string             `json:"payload"`
+	MaxRetries int                `json:"max_retries"`
+	Priority   jobqueue.Priority  `json:"priority"`
+	Overlap    cron.OverlapPolicy `json:"overlap"`
+	CatchUp    cron.CatchUpPolicy `json:"catch_up"`
+	CreatedAt  time.Time          `json:"created_at"`
+	LastTick   time.Time          `json:"last_tick,omitzero"`
+	LastJobID  string             `json:"last_job_id,omitempty"`
+	NextRun    time.Time          `json:"next_run,omitzero"`
+	Pending    bool               `json:"pending"`
+	Runs       int                `json:"runs"`
+	Skipped    int                `json:"skipped"`
+}
+
+// newScheduleView формирует ответ API из записи планировщика.
+func newScheduleView(e cron.Entry) scheduleView {
+	return scheduleView{
+		Name:       e.Name,
+		Spec:       e.Spec,
+		Payload:    e.Payload,
+		MaxRetries: e.MaxRetries,
+		Priority:   e.Priority,
+		Overlap:    e.Overlap,
+		CatchUp:    e.CatchUp,
+		CreatedAt:  e.CreatedAt,
+		LastTick:   e.LastTick,
+		LastJobID:  e.LastJobID,
+		NextRun:    e.Next,
+		Pending:    !e.Pending.IsZero(),
+		Runs:       e.Runs,
+		Skipped:    e.Skipped,
+	}
+}
+
+// putSchedule разбирает тело запроса и сохраняет расписание с именем name
+// (пустое name берётся из тела). Отвечает 201 при создании и 200 при изменении;
+// если create и расписание уже существует — 409.
+func (a *App) putSchedule(w http.ResponseWriter, r *http.Request, name string, create bool) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if name == "" {
+		name = req.Name
+	} else if req.Name != "" && req.Name != name {
+		http.Error(w, "name does not match path", http.StatusBadRequest)
+		return
+	}
+	if len(req.Payload) > 1<<20 {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if req.MaxRetries < 0 || req.MaxRetries > 10 {
+		http.Error(w, "max_retries must be between 0 and 10", http.StatusBadRequest)
+		return
+	}
+	if _, exists := a.sched.Get(name); exists && create {
+		http.Error(w, "schedule already exists", http.StatusConflict)
+		return
+	}
+	e, created, err := a.sched.Put(cron.Definition{
+		Name:       name,
+		Spec:       req.Spec,
+		Payload:    req.Payload,
+		MaxRetries: req.MaxRetries,
+		Priority:   jobqueue.Priority(req.Priority),
+		Overlap:    cron.OverlapPolicy(req.Overlap),
+		CatchUp:    cron.CatchUpPolicy(req.CatchUp),
+	})
+	switch {
+	case errors.Is(err, cron.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		a.reqLog(r).Error("schedule put error", "schedule", name, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	a.reqLog(r).Info("schedule saved", "schedule", e.Name, "spec", e.Spec, "next_run", e.Next)
+	writeJSON(w, status, newScheduleView(e))
+}
+
+// handleSchedules обрабатывает /schedules: GET — список расписаний, POST — создание.
+func (a *App) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		entries := a.sched.List()
+		resp := struct {
+			Schedules []scheduleView `json:"schedules"`
+		}{Schedules: make([]scheduleView, 0, len(entries))}
+		for _, e := range entries {
+			resp.Schedules = append(resp.Schedules, newScheduleView(e))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPost:
+		a.putSchedule(w, r, "", true)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSchedule обрабатывает /schedules/{name}: GET — просмотр, PUT — создание или замена,
+// DELETE — удаление (уже поставленные задания не отменяются).
+func (a *App) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodGet:
+		e, ok := a.sched.Get(name)
+		if !ok {
+			http.Error(w, "schedule not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, newScheduleView(e))
+	case http.MethodPut:
+		a.putSchedule(w, r, name, false)
+	case http.MethodDelete:
+		removed, err := a.sched.Delete(name)
+		if err != nil {
+			a.reqLog(r).Error("schedule delete error", "schedule", name, "err", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !removed {
+			http.Error(w, "schedule not found", http.StatusNotFound)
+			return
+		}
+		a.reqLog(r).Info("schedule deleted", "schedule", name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
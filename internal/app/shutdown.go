@@ -0,0 +1,260 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+)
+
+// ShutdownReport — итог остановки сервиса.
+type ShutdownReport struct {
+	Drained      int  // задания, завершившиеся (done или failed) во время остановки
+	Cancelled    int  // задания, отменённые пользователем во время остановки
+	Interrupted  int  // выполнявшиеся задания, прерванные по истечении срока дообработки; входят в Checkpointed или Dropped
+	Checkpointed int  // невыполненные задания, сохранённые в файл контрольной точки
+	Journaled    int  // невыполненные задания, оставленные в журнале очереди до следующего запуска
+	Dropped      int  // невыполненные задания, потерянные из-за отсутствия или ошибки контрольной точки
+	TimedOut     bool // срок дообработки истёк, выполнявшиеся задания прерваны
+	Duration     time.Duration
+}
+
+// shutdownStats считает завершения заданий во время остановки. Заполняется наблюдателем очереди.
+type shutdownStats struct {
+	active    atomic.Bool
+	drained   atomic.Int64
+	cancelled atomic.Int64
+
+	mu          sync.Mutex
+	interrupted []jobqueue.Job // задания, прерванные отменой workCtx; остаются в состоянии running
+}
+
+// interrupt запоминает задание, выполнение которого прервано по истечении срока дообработки.
+func (s *shutdownStats) interrupt(job jobqueue.Job) {
+	s.mu.Lock()
+	s.interrupted = append(s.interrupted, job)
+	s.mu.Unlock()
+}
+
+// takeInterrupted возвращает прерванные задания и очищает список.
+func (s *shutdownStats) takeInterrupted() []jobqueue.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.interrupted
+	s.interrupted = nil
+	return out
+}
+
+// observe учитывает переходы в завершённые состояния после начала остановки. Вызывается под мьютексом очереди.
+func (s *shutdownStats) observe(ev jobqueue.Event) {
+	if ev.Type != jobqueue.EventTransition || !s.active.Load() {
+		return
+	}
+	switch ev.Job.State {
+	case jobqueue.StateDone, jobqueue.StateFailed:
+		s.drained.Add(1)
+	case jobqueue.StateCancelled:
+		s.cancelled.Add(1)
+	}
+}
+
+// drainTimeout возвращает срок дообработки заданий при остановке; 0 — без ограничения.
+func (a *App) drainTimeout() time.Duration {
+	if a.cfg.DrainTimeoutMs > 0 {
+		return time.Duration(a.cfg.DrainTimeoutMs) * time.Millisecond
+	}
+	return 0
+}
+
+// gracefulStop прекращает приём новых задач, останавливает планировщики, закрывает основную
+// и именованные очереди и дожидается воркеров не дольше общего срока дообработки. По его истечении
+// выполняющиеся задания прерываются через контекст и вместе с оставшимися в очередях и не наступившими
+// отложенными заданиями сохраняются в контрольные точки. Затем прерывает ожидания GET /jobs/{id}/wait, останавливает
+// отправку уведомлений (недоставленные остаются в журнале доставок), закрывает потоки событий
+// и останавливает HTTP-сервер. Итог суммируется по всем очередям.
+func (a *App) gracefulStop(srv *http.Server, acceptingMu *sync.Mutex, accepting *bool, wg *sync.WaitGroup) ShutdownReport {
+	start := time.Now()
+	apps := a.apps()
+	for _, x := range apps {
+		x.shutdown.active.Store(true)
+	}
+	acceptingMu.Lock()
+	*accepting = false
+	acceptingMu.Unlock()
+	for _, x := range apps {
+		x.pool.close()
+		x.sched.Stop()
+		x.q.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		for _, nq := range a.queues {
+			nq.wg.Wait()
+		}
+		close(done)
+	}()
+	var report ShutdownReport
+	var deadline <-chan time.Time
+	if d := a.drainTimeout(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		deadline = t.C
+	}
+	select {
+	case <-done:
+	case <-deadline:
+		report.TimedOut = true
+		a.log.Warn("drain timeout: interrupting running jobs", "drain_timeout_ms", a.drainTimeout().Milliseconds())
+		for _, x := range apps {
+			x.abortWork()
+		}
+		<-done
+	}
+
+	for _, x := range apps {
+		interrupted := x.shutdown.takeInterrupted()
+		checkpointed, journaled, dropped := x.checkpointPending(interrupted)
+		report.Interrupted += len(interrupted)
+		report.Checkpointed += checkpointed
+		report.Journaled += journaled
+		report.Dropped += dropped
+		x.stop()
+		x.events.Close()
+		report.Drained += int(x.shutdown.drained.Load())
+		report.Cancelled += int(x.shutdown.cancelled.Load())
+	}
+	a.hooks.Stop()
+	_ = srv.Shutdown(context.Background())
+
+	report.Duration = time.Since(start)
+	a.log.Info("shutdown complete", "drained", report.Drained, "cancelled", report.Cancelled,
+		"interrupted", report.Interrupted, "checkpointed", report.Checkpointed, "journaled", report.Journaled, "dropped", report.Dropped, "timed_out", report.TimedOut,
+		"duration_ms", report.Duration.Milliseconds())
+	return report
+}
+
+// checkpointPending сохраняет прерванные, оставшиеся в очереди и не наступившие отложенные задания
+// в контрольную точку и возвращает число сохранённых, оставленных в журнале и потерянных заданий.
+// При включённом журнале очереди (WAL_DIR) задания остаются в нём и восстанавливаются при следующем
+// запуске, поэтому контрольная точка не пишется, чтобы не восстанавливать их дважды.
+func (a *App) checkpointPending(interrupted []jobqueue.Job) (checkpointed, journaled, dropped int) {
+	if a.cfg.WALDir != "" {
+		return 0, len(interrupted) + a.q.Len() + len(a.q.Unscheduled()), 0
+	}
+	pending := append(append(interrupted, a.q.TakePending()...), a.q.Unscheduled()...)
+	if len(pending) == 0 {
+		return 0, 0, 0
+	}
+	if err := a.writeCheckpoint(pending); err != nil {
+		for i, job := range pending {
+			state := jobqueue.StateQueued
+			if i < len(interrupted) {
+				state = jobqueue.StateRunning
+			}
+			a.jobLog(job.ID, job.RequestID).Warn("dropped on shutdown", "state", state)
+		}
+		if !errors.Is(err, errNoCheckpoint) {
+			a.log.Error("checkpoint write failed", "path", a.cfg.CheckpointPath, "err", err)
+		}
+		return 0, 0, len(pending)
+	}
+	return len(pending), 0, 0
+}
+
+// errNoCheckpoint — путь контрольной точки не задан.
+var errNoCheckpoint = errors.New("checkpoint disabled")
+
+// writeCheckpoint сохраняет задания в файл контрольной точки (JSON lines), заменяя его атомарно.
+func (a *App) writeCheckpoint(jobs []jobqueue.Job) error {
+	path := a.cfg.CheckpointPath
+	if path == "" {
+		return errNoCheckpoint
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, job := range jobs {
+		if err = enc.Encode(job); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// restoreCheckpoint ставит в очередь задания из контрольной точки прошлой остановки и удаляет файл.
+// Задания, уже восстановленные из журнала очереди, пропускаются; не поместившиеся в очередь
+// остаются в файле до следующего запуска. Возвращает число поставленных заданий.
+func (a *App) restoreCheckpoint() (int, error) {
+	path := a.cfg.CheckpointPath
+	if path == "" {
+		return 0, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var jobs []jobqueue.Job
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var job jobqueue.Job
+		if err := dec.Decode(&job); err != nil {
+			_ = f.Close()
+			return 0, fmt.Errorf("checkpoint %s: %w", path, err)
+		}
+		jobs = append(jobs, job)
+	}
+	_ = f.Close()
+
+	restored := 0
+	for i, job := range jobs {
+		err := a.q.Rerun(job)
+		switch {
+		case err == nil:
+			restored++
+		case errors.Is(err, jobqueue.ErrDuplicate):
+			// задание уже активно: восстановлено из журнала очереди
+		case errors.Is(err, jobqueue.ErrFull):
+			if werr := a.writeCheckpoint(jobs[i:]); werr != nil {
+				return restored, werr
+			}
+			return restored, fmt.Errorf("checkpoint: %d jobs left for the next start: %w", len(jobs)-i, err)
+		default:
+			return restored, err
+		}
+	}
+	return restored, os.Remove(path)
+}
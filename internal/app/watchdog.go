@@ -0,0 +1,292 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/processing"
+)
+
+const (
+	// defaultJobTimeout — таймаут попытки, если он не задан ни в задании, ни в конфигурации.
+	defaultJobTimeout = time.Minute
+	// defaultJobTimeoutMax — верхняя граница timeout_ms, если она не задана в конфигурации.
+	defaultJobTimeoutMax = 10 * time.Minute
+	// defaultWatchdogGrace — сколько ждать возврата процессора после дедлайна, прежде чем бросить попытку.
+	defaultWatchdogGrace = 5 * time.Second
+)
+
+// jobTimeout возвращает таймаут попытки задания: заданный при постановке или по умолчанию из конфигурации.
+func (a *App) jobTimeout(job jobqueue.Job) time.Duration {
+	switch {
+	case job.Timeout > 0:
+		return job.Timeout
+	case a.cfg.JobTimeoutMs > 0:
+		return time.Duration(a.cfg.JobTimeoutMs) * time.Millisecond
+	}
+	return defaultJobTimeout
+}
+
+// jobTimeoutMax возвращает верхнюю границу timeout_ms при постановке.
+func (a *App) jobTimeoutMax() time.Duration {
+	if a.cfg.JobTimeoutMaxMs > 0 {
+		return time.Duration(a.cfg.JobTimeoutMaxMs) * time.Millisecond
+	}
+	return defaultJobTimeoutMax
+}
+
+// watchdogGrace возвращает запас после дедлайна, по истечении которого попытка считается зависшей.
+func (a *App) watchdogGrace() time.Duration {
+	if a.cfg.WatchdogGraceMs > 0 {
+		return time.Duration(a.cfg.WatchdogGraceMs) * time.Millisecond
+	}
+	return defaultWatchdogGrace
+}
+
+// runningAttempt описывает выполняющуюся попытку обработки.
+type runningAttempt struct {
+	Worker    int
+	JobID     string
+	Attempt   int
+	StartedAt time.Time
+	Deadline  time.Time
+	// AbandonedAt — когда воркер перестал ждать процессор, не вернувшийся после дедлайна; нулевое — попытка не брошена.
+	AbandonedAt time.Time
+}
+
+// watchdog отслеживает попытки воркеров и брошенные попытки, чей процессор так и не вернулся.
+type watchdog struct {
+	mu      sync.Mutex
+	running map[int]runningAttempt    // по номеру воркера
+	stuck   map[uint64]runningAttempt // брошенные попытки, процессор которых ещё выполняется
+	seq     uint64
+	panics  map[int]int // перехваченные паники по воркерам
+	restart map[int]int // перезапуски слота воркера после паники
+}
+
+func newWatchdog() *watchdog {
+	return &watchdog{
+		running: make(map[int]runningAttempt),
+		stuck:   make(map[uint64]runningAttempt),
+		panics:  make(map[int]int),
+		restart: make(map[int]int),
+	}
+}
+
+// panicked учитывает панику в воркере; restarted — слот воркера перезапущен.
+func (w *watchdog) panicked(worker int, restarted bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.panics[worker]++
+	if restarted {
+		w.restart[worker]++
+	}
+}
+
+// panicStats возвращает число паник и перезапусков воркера.
+func (w *watchdog) panicStats(worker int) (panics, restarts int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.panics[worker], w.restart[worker]
+}
+
+func (w *watchdog) begin(ra runningAttempt) {
+	w.mu.Lock()
+	w.running[ra.Worker] = ra
+	w.mu.Unlock()
+}
+
+func (w *watchdog) end(worker int) {
+	w.mu.Lock()
+	delete(w.running, worker)
+	w.mu.Unlock()
+}
+
+// abandon переносит попытку воркера в зависшие и возвращает функцию, снимающую отметку,
+// когда процессор всё-таки вернётся.
+func (w *watchdog) abandon(worker int) (release func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ra := w.running[worker]
+	delete(w.running, worker)
+	ra.AbandonedAt = time.Now()
+	w.seq++
+	id := w.seq
+	w.stuck[id] = ra
+	return func() {
+		w.mu.Lock()
+		delete(w.stuck, id)
+		w.mu.Unlock()
+	}
+}
+
+// snapshot возвращает выполняющиеся попытки по воркерам и брошенные попытки в порядке начала.
+func (w *watchdog) snapshot() (running map[int]runningAttempt, stuck []runningAttempt) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	running = make(map[int]runningAttempt, len(w.running))
+	for k, v := range w.running {
+		running[k] = v
+	}
+	for _, ra := range w.stuck {
+		stuck = append(stuck, ra)
+	}
+	slices.SortFunc(stuck, func(x, y runningAttempt) int { return x.StartedAt.Compare(y.StartedAt) })
+	return running, stuck
+}
+
+// overdue возвращает число воркеров, чья текущая попытка выполняется дольше дедлайна.
+func (w *watchdog) overdue(now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, ra := range w.running {
+		if now.After(ra.Deadline) {
+			n++
+		}
+	}
+	return n
+}
+
+// stuckCount возвращает число брошенных попыток, процессор которых ещё выполняется.
+func (w *watchdog) stuckCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.stuck)
+}
+
+// attemptOutcome — итог вызова процессора.
+type attemptOutcome struct {
+	res processing.Result
+	err error
+}
+
+// runAttempt вызывает процессор с дедлайном попытки. Ошибка процессора после истечения дедлайна
+// превращается в повторяемую ошибку с кодом timeout. Если процессор не вернулся за запас после дедлайна
+// (или после отмены ctx), воркер бросает попытку: она засчитывается как timeout, а сам вызов
+// остаётся в диагностике зависших до возврата.
+func (a *App) runAttempt(ctx context.Context, log *slog.Logger, worker int, job processing.Job, timeout time.Duration) (processing.Result, error) {
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := actx.Deadline()
+	a.watch.begin(runningAttempt{Worker: worker, JobID: job.ID, Attempt: job.Attempt, StartedAt: time.Now(), Deadline: deadline})
+
+	done := make(chan attemptOutcome, 1)
+	go func() {
+		defer func() {
+			// Паника процессора — неуспешная попытка по обычной политике повторов.
+			if v := recover(); v != nil {
+				a.metrics.panics.Inc(a.metrics.queue, "processor")
+				a.watch.panicked(worker, false)
+				done <- attemptOutcome{err: newPanicError(v)}
+			}
+		}()
+		res, err := a.proc.Process(actx, job)
+		done <- attemptOutcome{res, err}
+	}()
+	var out attemptOutcome
+	select {
+	case out = <-done:
+	case <-actx.Done():
+		grace := time.NewTimer(a.watchdogGrace())
+		defer grace.Stop()
+		select {
+		case out = <-done:
+		case <-grace.C:
+			release := a.watch.abandon(worker)
+			go func() {
+				<-done
+				release()
+				log.Info("abandoned attempt returned", "attempt", job.Attempt)
+			}()
+			a.metrics.timeouts.Inc(a.metrics.queue)
+			log.Warn("attempt abandoned: processor did not return", "attempt", job.Attempt, "timeout_ms", timeout.Milliseconds())
+			if ctx.Err() != nil {
+				return processing.Result{}, ctx.Err()
+			}
+			return processing.Result{}, timeoutError(timeout)
+		}
+	}
+	a.watch.end(worker)
+	if out.err != nil && ctx.Err() == nil && errors.Is(actx.Err(), context.DeadlineExceeded) {
+		a.metrics.timeouts.Inc(a.metrics.queue)
+		return out.res, timeoutError(timeout)
+	}
+	return out.res, out.err
+}
+
+// timeoutError — повторяемая ошибка попытки, превысившей таймаут.
+func timeoutError(timeout time.Duration) error {
+	return &processing.Error{Code: "timeout", Message: fmt.Sprintf("attempt exceeded timeout of %s", timeout), Err: context.DeadlineExceeded}
+}
+
+// workerView — состояние воркера в диагностике.
+type workerView struct {
+	Worker      int       `json:"worker"`
+	State       string    `json:"state"`              // idle или busy
+	Stopping    bool      `json:"stopping,omitempty"` // воркер убран из пула и завершится после текущего задания
+	JobID       string    `json:"job_id,omitempty"`
+	Attempt     int       `json:"attempt,omitempty"`
+	StartedAt   time.Time `json:"started_at,omitzero"`
+	Deadline    time.Time `json:"deadline,omitzero"`
+	RunningMs   int64     `json:"running_ms,omitempty"`
+	Overdue     bool      `json:"overdue,omitempty"`
+	AbandonedAt time.Time `json:"abandoned_at,omitzero"`
+	Panics      int       `json:"panics,omitempty"`   // перехваченные паники воркера, в попытках и вне их
+	Restarts    int       `json:"restarts,omitempty"` // перезапуски слота после паники вне попытки
+}
+
+// workersDiagnostics — ответ GET /debug/workers.
+type workersDiagnostics struct {
+	Workers []workerView `json:"workers"`
+	// Stuck — брошенные попытки, процессор которых так и не вернулся; их горутины продолжают работать.
+	Stuck []workerView `json:"stuck"`
+}
+
+// newWorkerView формирует представление попытки на момент now.
+func newWorkerView(ra runningAttempt, now time.Time) workerView {
+	return workerView{
+		Worker:      ra.Worker,
+		State:       "busy",
+		JobID:       ra.JobID,
+		Attempt:     ra.Attempt,
+		StartedAt:   ra.StartedAt,
+		Deadline:    ra.Deadline,
+		RunningMs:   now.Sub(ra.StartedAt).Milliseconds(),
+		Overdue:     now.After(ra.Deadline),
+		AbandonedAt: ra.AbandonedAt,
+	}
+}
+
+// handleWorkers обрабатывает GET /debug/workers: текущие попытки воркеров с дедлайнами, счётчики паник
+// и брошенные попытки, процессор которых не вернулся после таймаута.
+func (a *App) handleWorkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	now := time.Now()
+	running, stuck := a.watch.snapshot()
+	ids, stopping := a.pool.slotIDs()
+	resp := workersDiagnostics{Workers: make([]workerView, 0, len(ids)), Stuck: make([]workerView, 0, len(stuck))}
+	for _, i := range ids {
+		v := workerView{Worker: i, State: "idle"}
+		if ra, ok := running[i]; ok {
+			v = newWorkerView(ra, now)
+		}
+		v.Stopping = stopping[i]
+		v.Panics, v.Restarts = a.watch.panicStats(i)
+		resp.Workers = append(resp.Workers, v)
+	}
+	for _, ra := range stuck {
+		resp.Stuck = append(resp.Stuck, newWorkerView(ra, now))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
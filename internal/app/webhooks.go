@@ -0,0 +1,222 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/webhook"
+)
+
+// maxCallbackURL — максимальная длина callback_url.
+const maxCallbackURL = 2048
+
+// jobNotification — тело уведомления о завершении задания.
+type jobNotification struct {
+	Event      string         `json:"event"` // job.done или job.failed
+	JobID      string         `json:"job_id"`
+	State      jobqueue.State `json:"state"`
+	Attempts   int            `json:"attempts"`
+	LastError  string         `json:"last_error,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	EnqueuedAt time.Time      `json:"enqueued_at,omitzero"`
+	FinishedAt time.Time      `json:"finished_at,omitzero"`
+	History    []attemptView  `json:"history"`
+	// Result — результат обработки для job.done, если процессор его вернул.
+	Result            string `json:"result,omitempty"`
+	ResultContentType string `json:"result_content_type,omitempty"`
+}
+
+// validCallbackURL проверяет callback_url. Возвращает текст ошибки для ответа 400 или пустую строку.
+func (a *App) validCallbackURL(raw string) string {
+	if a.cfg.WebhookSecret == "" {
+		return "callback_url requires WEBHOOK_SECRET to be configured"
+	}
+	if len(raw) > maxCallbackURL {
+		return "callback_url too long"
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "callback_url must be an absolute http or https URL"
+	}
+	return ""
+}
+
+// notify ставит в отправку уведомление о завершении задания, если у него задан callback_url.
+// Вызывается после перевода задания в done или failed.
+func (a *App) notify(log *slog.Logger, job jobqueue.Job) {
+	if job.CallbackURL == "" {
+		return
+	}
+	ji, err := a.q.Get(job.ID)
+	if err != nil {
+		log.Error("webhook: job state lookup failed", "err", err)
+		return
+	}
+	n := jobNotification{
+		Event:      "job." + string(ji.State),
+		JobID:      ji.ID,
+		State:      ji.State,
+		Attempts:   ji.Attempts,
+		LastError:  ji.LastError,
+		RequestID:  ji.RequestID,
+		EnqueuedAt: ji.EnqueuedAt,
+		FinishedAt: ji.FinishedAt,
+		History:    newAttemptViews(ji.History),
+	}
+	if ji.Result != nil {
+		n.Result, n.ResultContentType = ji.Result.Data, ji.Result.ContentType
+	}
+	del, err := a.hooks.Enqueue(job.ID, job.CallbackURL, n.Event, n)
+	if err != nil {
+		log.Error("webhook: enqueue failed", "err", err)
+		return
+	}
+	log.Debug("webhook scheduled", "delivery_id", del.ID, "event", n.Event)
+}
+
+// deliveryAttemptView — представление попытки доставки в ответах HTTP API.
+type deliveryAttemptView struct {
+	Number     int       `json:"number"`
+	At         time.Time `json:"at"`
+	DurationMs int64     `json:"duration_ms"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// deliveryView — представление доставки уведомления в ответах HTTP API.
+type deliveryView struct {
+	ID          uint64                `json:"id"`
+	JobID       string                `json:"job_id"`
+	URL         string                `json:"url"`
+	Event       string                `json:"event"`
+	State       webhook.State         `json:"state"`
+	Payload     json.RawMessage       `json:"payload"`
+	MaxAttempts int                   `json:"max_attempts"`
+	CreatedAt   time.Time             `json:"created_at"`
+	NextAt      time.Time             `json:"next_attempt_at,omitzero"`
+	FinishedAt  time.Time             `json:"finished_at,omitzero"`
+	Attempts    []deliveryAttemptView `json:"attempts"`
+}
+
+// deliveryList — страница листинга доставок.
+type deliveryList struct {
+	Deliveries []deliveryView `json:"deliveries"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// newDeliveryView формирует ответ API из записи журнала доставок.
+func newDeliveryView(d webhook.Delivery) deliveryView {
+	v := deliveryView{
+		ID:          d.ID,
+		JobID:       d.JobID,
+		URL:         d.URL,
+		Event:       d.Event,
+		State:       d.State,
+		Payload:     d.Payload,
+		MaxAttempts: d.MaxAttempts,
+		CreatedAt:   d.CreatedAt,
+		FinishedAt:  d.FinishedAt,
+		Attempts:    make([]deliveryAttemptView, 0, len(d.Attempts)),
+	}
+	if d.State == webhook.StatePending {
+		v.NextAt = d.NextAt
+	}
+	for _, at := range d.Attempts {
+		v.Attempts = append(v.Attempts, deliveryAttemptView{
+			Number:     at.Number,
+			At:         at.At,
+			DurationMs: at.Duration.Milliseconds(),
+			StatusCode: at.StatusCode,
+			Error:      at.Error,
+		})
+	}
+	return v
+}
+
+// handleDeliveries обрабатывает GET /webhooks/deliveries: постраничный листинг журнала доставок
+// с фильтрами по заданию и состоянию.
+func (a *App) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	qv := r.URL.Query()
+	p, msg := parsePageParams(qv)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	f := webhook.Filter{JobID: qv.Get("job_id"), State: webhook.State(qv.Get("state")), After: p.After, Limit: p.Limit}
+	switch f.State {
+	case "", webhook.StatePending, webhook.StateDelivered, webhook.StateFailed:
+	default:
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+	items, next := a.hooks.List(f)
+	resp := deliveryList{Deliveries: make([]deliveryView, 0, len(items)), NextCursor: encodeCursor(next)}
+	for _, d := range items {
+		resp.Deliveries = append(resp.Deliveries, newDeliveryView(d))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// deliveryID разбирает идентификатор доставки из пути; при ошибке отвечает 404.
+func deliveryID(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "delivery not found", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+// handleDelivery обрабатывает GET /webhooks/deliveries/{id}: доставка с историей попыток.
+func (a *App) handleDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := deliveryID(w, r)
+	if !ok {
+		return
+	}
+	d, err := a.hooks.Get(id)
+	if err != nil {
+		http.Error(w, "delivery not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, newDeliveryView(d))
+}
+
+// handleRedeliver обрабатывает POST /webhooks/deliveries/{id}/redeliver: повторная отправка
+// завершённой доставки (202). Для ещё не завершённой доставки — 409.
+func (a *App) handleRedeliver(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := deliveryID(w, r)
+	if !ok {
+		return
+	}
+	d, err := a.hooks.Redeliver(id)
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		http.Error(w, "delivery not found", http.StatusNotFound)
+	case errors.Is(err, webhook.ErrPending):
+		http.Error(w, "delivery is pending", http.StatusConflict)
+	case err != nil:
+		a.reqLog(r).Error("redeliver error", "delivery_id", id, "err", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	default:
+		a.reqLog(r).Info("redeliver", "delivery_id", id, "job_id", d.JobID)
+		writeJSON(w, http.StatusAccepted, newDeliveryView(d))
+	}
+}
@@ -10,6 +10,65 @@ type Config struct {
 	Workers   int
 	QueueSize int
 	ErrorRate int // 0..100, процент неуспеха обработки
+
+	Queues string // именованные очереди, см. ParseQueues; пусто — только основная очередь
+
+	WALDir           string // каталог журнала очереди; пусто — журнал отключён
+	WALFsync         string // политика fsync журнала: always | interval | never
+	WALFsyncInterval int    // период fsync в миллисекундах для политики interval
+
+	Store     string // бэкенд хранилища состояний: memory | file
+	StorePath string // путь к файлу хранилища для Store=file
+
+	DLQPath string // файл dead-letter очереди; пусто — DLQ только в памяти
+
+	DuplicatePolicy string // обработка повторных id: reject | replay | rerun
+
+	PriorityWeights string // веса полос приоритетов, например "high=6,normal=3,low=1"
+
+	SchedulesPath string // файл повторяющихся расписаний; пусто — расписания только в памяти
+
+	LogFormat string // формат логов: text | json
+	LogLevel  string // минимальный уровень логов: debug | info | warn | error
+
+	JobTimeoutMs    int // таймаут попытки обработки по умолчанию, в миллисекундах
+	JobTimeoutMaxMs int // верхняя граница timeout_ms задания, в миллисекундах
+	WatchdogGraceMs int // сколько ждать возврата процессора после дедлайна, прежде чем бросить попытку
+
+	ReadyQueueHighWater int // заполненность очереди в процентах, начиная с которой GET /readyz не проходит
+
+	WorkersMin            int  // нижняя граница размера пула для автоскейлера
+	WorkersMax            int  // верхняя граница размера пула при изменении на ходу
+	Autoscale             bool // автомасштабирование пула по глубине очереди и длительности попыток
+	AutoscaleIntervalMs   int  // период решений автоскейлера в миллисекундах
+	AutoscaleTargetWaitMs int  // за сколько миллисекунд автоскейлер стремится разобрать очередь
+
+	DrainTimeoutMs int    // срок дообработки заданий при остановке в миллисекундах; 0 — без ограничения
+	CheckpointPath string // файл невыполненных при остановке заданий; пусто — такие задания теряются, если не включён журнал
+
+	ResultMaxBytes   int // максимальный размер результата задания; больший результат — ошибка попытки
+	ResultTTLSeconds int // срок хранения результата; 0 — бессрочно
+
+	WebhookSecret      string // ключ подписи HMAC-SHA256 уведомлений; пусто — callback_url не принимается
+	WebhookMaxAttempts int    // попыток доставки одного уведомления
+	WebhookTimeoutMs   int    // таймаут одной попытки доставки в миллисекундах
+	WebhooksPath       string // файл журнала доставок; пусто — журнал только в памяти
+	WebhookRetentionS  int    // срок хранения завершённых доставок в секундах; 0 — бессрочно
+
+	EventsBuffer int // число последних событий, доступных для возобновления потока GET /events
+
+	TraceExporter     string // экспорт спанов: none | file | otlp
+	TraceFile         string // файл спанов в формате JSON lines для TraceExporter=file
+	TraceOTLPEndpoint string // URL приёма спанов OTLP/HTTP для TraceExporter=otlp
+	TraceServiceName  string // значение service.name в экспортируемых спанах
+}
+
+// getenvString читает переменную окружения как строку или возвращает значение по умолчанию.
+func getenvString(key string, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
 }
 
 // getenvInt читает переменную окружения как целое число или возвращает значение по умолчанию.
@@ -25,11 +84,75 @@ func getenvInt(key string, def int) int {
 	return n
 }
 
+// getenvBool читает переменную окружения как булево значение или возвращает значение по умолчанию.
+func getenvBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
 // Load создаёт конфигурацию из переменных окружения.
 func Load() Config {
 	return Config{
 		Workers:   getenvInt("WORKERS", 4),
 		QueueSize: getenvInt("QUEUE_SIZE", 64),
 		ErrorRate: getenvInt("ERROR_RATE", 20),
+
+		Queues: getenvString("QUEUES", ""),
+
+		WALDir:           getenvString("WAL_DIR", ""),
+		WALFsync:         getenvString("WAL_FSYNC", "always"),
+		WALFsyncInterval: getenvInt("WAL_FSYNC_INTERVAL_MS", 1000),
+
+		Store:     getenvString("STORE", "memory"),
+		StorePath: getenvString("STORE_PATH", "data/jobs.db"),
+
+		DLQPath: getenvString("DLQ_PATH", ""),
+
+		DuplicatePolicy: getenvString("DUPLICATE_POLICY", "replay"),
+
+		PriorityWeights: getenvString("PRIORITY_WEIGHTS", ""),
+
+		SchedulesPath: getenvString("SCHEDULES_PATH", ""),
+
+		LogFormat: getenvString("LOG_FORMAT", "text"),
+		LogLevel:  getenvString("LOG_LEVEL", "info"),
+
+		JobTimeoutMs:    getenvInt("JOB_TIMEOUT_MS", 60000),
+		JobTimeoutMaxMs: getenvInt("JOB_TIMEOUT_MAX_MS", 600000),
+		WatchdogGraceMs: getenvInt("WATCHDOG_GRACE_MS", 5000),
+
+		ReadyQueueHighWater: getenvInt("READY_QUEUE_HIGH_WATER", 90),
+
+		WorkersMin:            getenvInt("WORKERS_MIN", 1),
+		WorkersMax:            getenvInt("WORKERS_MAX", 64),
+		Autoscale:             getenvBool("AUTOSCALE", false),
+		AutoscaleIntervalMs:   getenvInt("AUTOSCALE_INTERVAL_MS", 5000),
+		AutoscaleTargetWaitMs: getenvInt("AUTOSCALE_TARGET_WAIT_MS", 1000),
+
+		DrainTimeoutMs: getenvInt("DRAIN_TIMEOUT_MS", 30000),
+		CheckpointPath: getenvString("CHECKPOINT_PATH", ""),
+
+		ResultMaxBytes:   getenvInt("RESULT_MAX_BYTES", 1<<20),
+		ResultTTLSeconds: getenvInt("RESULT_TTL_SECONDS", 86400),
+
+		WebhookSecret:      getenvString("WEBHOOK_SECRET", ""),
+		WebhookMaxAttempts: getenvInt("WEBHOOK_MAX_ATTEMPTS", 5),
+		WebhookTimeoutMs:   getenvInt("WEBHOOK_TIMEOUT_MS", 10000),
+		WebhooksPath:       getenvString("WEBHOOKS_PATH", ""),
+		WebhookRetentionS:  getenvInt("WEBHOOK_RETENTION_SECONDS", 604800),
+
+		EventsBuffer: getenvInt("EVENTS_BUFFER", 1024),
+
+		TraceExporter:     getenvString("TRACE_EXPORTER", "none"),
+		TraceFile:         getenvString("TRACE_FILE", "data/traces.jsonl"),
+		TraceOTLPEndpoint: getenvString("TRACE_OTLP_ENDPOINT", "http://localhost:4318/v1/traces"),
+		TraceServiceName:  getenvString("TRACE_SERVICE_NAME", "kaspgo-jobqueue"),
 	}
 }
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// QueueConfig — параметры именованной очереди из переменной QUEUES.
+// Нулевые поля означают значения основной очереди.
+type QueueConfig struct {
+	Name          string
+	Size          int // ёмкость очереди
+	Workers       int // начальный размер пула воркеров
+	ErrorRate     int // процент неуспеха симуляции обработки; -1 — как ERROR_RATE
+	BackoffBaseMs int // базовая задержка бэкоффа
+	BackoffMaxMs  int // максимальная задержка бэкоффа
+}
+
+// maxQueueNameLen — максимальная длина имени очереди.
+const maxQueueNameLen = 64
+
+// ParseQueues разбирает описание именованных очередей в формате
+// "emails:size=32,workers=2,error_rate=5;reports:workers=1,backoff_max_ms=60000".
+// Допустимые параметры: size, workers, error_rate, backoff_base_ms, backoff_max_ms.
+// Имя — строчные латинские буквы, цифры, '-' и '_'; имя default занято основной очередью.
+func ParseQueues(s string) ([]QueueConfig, error) {
+	var out []QueueConfig
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, params, _ := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if err := validQueueName(name); err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate queue %q", name)
+		}
+		seen[name] = true
+		qc := QueueConfig{Name: name, ErrorRate: -1}
+		for _, kv := range strings.Split(params, ",") {
+			kv = strings.TrimSpace(kv)
+			if kv == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("queue %s: invalid parameter %q", name, kv)
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(val))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("queue %s: invalid parameter %q", name, kv)
+			}
+			switch strings.TrimSpace(key) {
+			case "size":
+				qc.Size = n
+			case "workers":
+				qc.Workers = n
+			case "error_rate":
+				if n > 100 {
+					return nil, fmt.Errorf("queue %s: error_rate must be between 0 and 100", name)
+				}
+				qc.ErrorRate = n
+			case "backoff_base_ms":
+				qc.BackoffBaseMs = n
+			case "backoff_max_ms":
+				qc.BackoffMaxMs = n
+			default:
+				return nil, fmt.Errorf("queue %s: unknown parameter %q", name, key)
+			}
+		}
+		out = append(out, qc)
+	}
+	return out, nil
+}
+
+// validQueueName проверяет имя именованной очереди.
+func validQueueName(name string) error {
+	if name == "" || len(name) > maxQueueNameLen {
+		return fmt.Errorf("invalid queue name %q", name)
+	}
+	if name == "default" {
+		return fmt.Errorf("queue name %q is reserved", name)
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+			return fmt.Errorf("invalid queue name %q", name)
+		}
+	}
+	return nil
+}
+
+// QueuePath возвращает файл именованной очереди name рядом с файлом основной очереди:
+// data/dlq.db → data/dlq-emails.db. Пустой путь (файл не используется) остаётся пустым.
+func QueuePath(path, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
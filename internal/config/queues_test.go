@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestParseQueues(t *testing.T) {
+	qs, err := ParseQueues(" emails:size=32,workers=2,error_rate=0 ; reports:backoff_base_ms=100,backoff_max_ms=60000;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []QueueConfig{
+		{Name: "emails", Size: 32, Workers: 2, ErrorRate: 0},
+		{Name: "reports", ErrorRate: -1, BackoffBaseMs: 100, BackoffMaxMs: 60000},
+	}
+	if len(qs) != len(want) || qs[0] != want[0] || qs[1] != want[1] {
+		t.Fatalf("got %+v, want %+v", qs, want)
+	}
+	if qs, err := ParseQueues(""); err != nil || len(qs) != 0 {
+		t.Fatalf("expected no queues, got %+v %v", qs, err)
+	}
+	for _, s := range []string{"default:size=1", "Emails", "a;a", "a:size", "a:size=-1", "a:workers=x", "a:error_rate=101", "a:colour=1"} {
+		if _, err := ParseQueues(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+	if got := QueuePath("data/dlq.db", "emails"); got != "data/dlq-emails.db" {
+		t.Fatalf("unexpected queue path %q", got)
+	}
+	if got := QueuePath("", "emails"); got != "" {
+		t.Fatalf("expected empty path to stay empty, got %q", got)
+	}
+}
@@ -0,0 +1,492 @@
+package cron
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/wal"
+)
+
+// OverlapPolicy определяет, что делать с тиком, если задание предыдущего запуска ещё не завершено.
+type OverlapPolicy string
+
+const (
+	// OverlapSkip пропускает тик.
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapAllow ставит новое задание независимо от предыдущего.
+	OverlapAllow OverlapPolicy = "allow"
+	// OverlapQueue откладывает тик до завершения предыдущего задания;
+	// несколько отложенных тиков сливаются в один запуск.
+	OverlapQueue OverlapPolicy = "queue"
+)
+
+// CatchUpPolicy определяет, как при старте обрабатываются тики, пропущенные во время простоя.
+type CatchUpPolicy string
+
+const (
+	// CatchUpNone отбрасывает пропущенные тики.
+	CatchUpNone CatchUpPolicy = "none"
+	// CatchUpOnce выполняет один запуск за все пропущенные тики.
+	CatchUpOnce CatchUpPolicy = "once"
+	// CatchUpAll выполняет каждый пропущенный тик, но не более MaxCatchUp последних.
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+// MaxCatchUp ограничивает число запусков, догоняемых при старте по CatchUpAll.
+const MaxCatchUp = 100
+
+// ErrInvalid — базовая ошибка некорректного определения расписания.
+var ErrInvalid = errors.New("invalid schedule")
+
+// namePattern ограничивает имя расписания: оно входит в идентификаторы заданий.
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Definition — определение повторяющегося задания.
+type Definition struct {
+	Name       string
+	Spec       string // выражение расписания, см. Parse
+	Payload    string
+	MaxRetries int
+	Priority   jobqueue.Priority
+	Overlap    OverlapPolicy // пусто — OverlapSkip
+	CatchUp    CatchUpPolicy // пусто — CatchUpOnce
+}
+
+// normalize проверяет определение, подставляет значения по умолчанию и разбирает расписание.
+func (d *Definition) normalize() (Schedule, error) {
+	if !namePattern.MatchString(d.Name) {
+		return nil, fmt.Errorf("%w: name must match %s", ErrInvalid, namePattern)
+	}
+	sched, err := Parse(d.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if d.Priority, err = jobqueue.ParsePriority(string(d.Priority)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	switch d.Overlap {
+	case "":
+		d.Overlap = OverlapSkip
+	case OverlapSkip, OverlapAllow, OverlapQueue:
+	default:
+		return nil, fmt.Errorf("%w: unknown overlap policy %q", ErrInvalid, d.Overlap)
+	}
+	switch d.CatchUp {
+	case "":
+		d.CatchUp = CatchUpOnce
+	case CatchUpNone, CatchUpOnce, CatchUpAll:
+	default:
+		return nil, fmt.Errorf("%w: unknown catch-up policy %q", ErrInvalid, d.CatchUp)
+	}
+	return sched, nil
+}
+
+// Entry — зарегистрированное расписание вместе с состоянием запусков.
+type Entry struct {
+	Definition
+	CreatedAt time.Time
+	LastTick  time.Time // последний обработанный тик: запущенный, пропущенный или отложенный
+	LastJobID string    // задание последнего запуска
+	Pending   time.Time // отложенный по OverlapQueue тик; нулевое — нет
+	Runs      int       // число поставленных заданий
+	Skipped   int       // число пропущенных тиков
+	Next      time.Time `json:"-"` // ближайший тик; вычисляется при выдаче
+
+	sched Schedule
+}
+
+// record — запись файла расписаний: сохранение записи либо её удаление.
+type record struct {
+	Put    *Entry `json:"put,omitempty"`
+	Delete string `json:"delete,omitempty"`
+}
+
+// Queue — операции очереди, нужные планировщику; реализуется *jobqueue.Queue.
+type Queue interface {
+	Enqueue(job jobqueue.Job) error
+	Get(id string) (jobqueue.JobInfo, error)
+}
+
+// PollInterval — период проверки завершения предыдущего задания для отложенных тиков.
+const PollInterval = time.Second
+
+// maxWait ограничивает сон планировщика, чтобы переводы часов не откладывали тики надолго.
+const maxWait = time.Minute
+
+// Scheduler ставит в очередь задания по зарегистрированным расписаниям.
+// Идентификатор задания выводится из имени расписания и времени тика
+// (<name>-20060102T150405Z). Тик запускается не более одного раза: LastTick
+// сохраняется до постановки задания, поэтому сбой между сохранением и постановкой
+// теряет тик, а не повторяет его. На дедупликацию очереди полагаться нельзя:
+// при DUPLICATE_POLICY=rerun завершённое задание с тем же идентификатором запускается заново.
+type Scheduler struct {
+	mu      sync.Mutex
+	q       Queue
+	entries map[string]*Entry
+	log     *wal.Log // nil — только в памяти
+	now     func() time.Time
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	started  bool
+}
+
+// New создаёт планировщик с расписаниями в памяти.
+func New(q Queue) *Scheduler {
+	return &Scheduler{
+		q:       q,
+		entries: make(map[string]*Entry),
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Open открывает (или создаёт) планировщик с сохранением расписаний и их состояния в файл path.
+// Файл компактизируется при каждом открытии. Пропущенные за время простоя тики
+// обрабатываются по CatchUp каждого расписания при Start.
+func Open(path string, sync wal.SyncPolicy, q Queue) (*Scheduler, error) {
+	l, err := wal.Open(wal.Options{Dir: filepath.Dir(path), Name: filepath.Base(path), Sync: sync})
+	if err != nil {
+		return nil, err
+	}
+	s := New(q)
+	err = l.Replay(func(data []byte) error {
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if rec.Put != nil {
+			s.entries[rec.Put.Name] = rec.Put
+		} else {
+			delete(s.entries, rec.Delete)
+		}
+		return nil
+	})
+	if err == nil {
+		for name, e := range s.entries {
+			var perr error
+			if e.sched, perr = e.Definition.normalize(); perr != nil {
+				slog.Warn("schedule dropped", "schedule", name, "err", perr)
+				delete(s.entries, name)
+			}
+		}
+	}
+	if err == nil {
+		err = l.Rewrite(s.snapshot())
+	}
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	s.log = l
+	return s, nil
+}
+
+// Put создаёт или заменяет расписание и сообщает, было ли оно создано.
+// Изменение существующего расписания сохраняет состояние его запусков.
+func (s *Scheduler) Put(def Definition) (Entry, bool, error) {
+	sched, err := def.normalize()
+	if err != nil {
+		return Entry{}, false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e := Entry{Definition: def, CreatedAt: now, LastTick: now, sched: sched}
+	prev, exists := s.entries[def.Name]
+	if exists {
+		e.CreatedAt, e.LastTick, e.LastJobID = prev.CreatedAt, prev.LastTick, prev.LastJobID
+		e.Pending, e.Runs, e.Skipped = prev.Pending, prev.Runs, prev.Skipped
+	}
+	if err := s.append(record{Put: &e}); err != nil {
+		return Entry{}, false, err
+	}
+	s.entries[def.Name] = &e
+	s.wakeup()
+	return e.view(), !exists, nil
+}
+
+// Get возвращает расписание по имени.
+func (s *Scheduler) Get(name string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return Entry{}, false
+	}
+	return e.view(), true
+}
+
+// List возвращает все расписания, упорядоченные по имени.
+func (s *Scheduler) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e.view())
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Name < out[k].Name })
+	return out
+}
+
+// Delete удаляет расписание и сообщает, было ли оно. Уже поставленные задания не отменяются.
+func (s *Scheduler) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[name]; !ok {
+		return false, nil
+	}
+	if err := s.append(record{Delete: name}); err != nil {
+		return false, err
+	}
+	delete(s.entries, name)
+	return true, nil
+}
+
+// Start обрабатывает пропущенные тики по CatchUp и запускает фоновый цикл планировщика.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	now := s.now()
+	for _, e := range s.entries {
+		s.catchUp(e, now)
+	}
+	go s.run()
+}
+
+// Stop останавливает фоновый цикл и дожидается его завершения.
+// Повторный запуск остановленного планировщика не поддерживается.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	s.stopOnce.Do(func() { close(s.stop) })
+	if started {
+		<-s.done
+	}
+}
+
+// Close закрывает файл расписаний, если он используется.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	return s.log.Close()
+}
+
+// run — цикл планировщика: обрабатывает наступившие тики и спит до ближайшего.
+func (s *Scheduler) run() {
+	defer close(s.done)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+		wait := s.tick(s.now())
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// wakeup будит цикл планировщика после изменения расписаний.
+func (s *Scheduler) wakeup() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// tick обрабатывает наступившие к now тики всех расписаний и возвращает время до следующей проверки.
+func (s *Scheduler) tick(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wait := maxWait
+	for _, e := range s.entries {
+		if !e.Pending.IsZero() {
+			if s.active(e) {
+				wait = min(wait, PollInterval)
+			} else {
+				tick := e.Pending
+				e.Pending = time.Time{}
+				s.enqueue(e, tick)
+				s.save(e)
+			}
+		}
+		// Если цикл отстал, несколько наступивших тиков сливаются в последний.
+		if due := dueTicks(e.sched, e.LastTick, now, 1); len(due) > 0 {
+			s.fire(e, due[0])
+		}
+		if next := e.sched.Next(e.LastTick); !next.IsZero() {
+			wait = min(wait, max(next.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// catchUp обрабатывает тики, пропущенные с LastTick до now, по политике CatchUp.
+// Догоняющие запуски ставятся без учёта Overlap. Вызывается под s.mu.
+func (s *Scheduler) catchUp(e *Entry, now time.Time) {
+	limit := 1
+	if e.CatchUp == CatchUpAll {
+		limit = MaxCatchUp
+	}
+	due := dueTicks(e.sched, e.LastTick, now, limit)
+	if len(due) == 0 {
+		return
+	}
+	slog.Info("schedule catch-up", "schedule", e.Name, "missed", len(due), "policy", e.CatchUp)
+	if e.CatchUp == CatchUpNone {
+		e.LastTick = due[len(due)-1]
+		e.Skipped += len(due)
+		s.save(e)
+		return
+	}
+	for _, tick := range due {
+		s.enqueue(e, tick)
+	}
+	s.save(e)
+}
+
+// fire обрабатывает наступивший тик с учётом Overlap. Вызывается под s.mu.
+func (s *Scheduler) fire(e *Entry, tick time.Time) {
+	if e.Overlap != OverlapAllow && s.active(e) {
+		if e.Overlap == OverlapQueue {
+			e.Pending = tick
+			slog.Info("schedule tick deferred", "schedule", e.Name, "tick", tick, "previous_job_id", e.LastJobID)
+		} else {
+			e.Skipped++
+			slog.Info("schedule tick skipped", "schedule", e.Name, "tick", tick, "previous_job_id", e.LastJobID)
+		}
+		e.LastTick = tick
+		s.save(e)
+		return
+	}
+	s.enqueue(e, tick)
+	s.save(e)
+}
+
+// enqueue продвигает и сохраняет LastTick, затем ставит задание тика в очередь. Вызывается под s.mu.
+func (s *Scheduler) enqueue(e *Entry, tick time.Time) {
+	if tick.After(e.LastTick) {
+		e.LastTick = tick
+	}
+	s.save(e)
+	job := jobqueue.Job{
+		ID:         JobID(e.Name, tick),
+		Payload:    e.Payload,
+		MaxRetries: e.MaxRetries,
+		Priority:   e.Priority,
+	}
+	err := s.q.Enqueue(job)
+	switch {
+	case err == nil:
+		e.LastJobID = job.ID
+		e.Runs++
+		slog.Info("schedule enqueued", "schedule", e.Name, "job_id", job.ID)
+	case errors.Is(err, jobqueue.ErrDuplicate):
+		// Задание тика ещё активно, например расписание пересоздано с тем же именем.
+		e.LastJobID = job.ID
+	default:
+		e.Skipped++
+		slog.Warn("schedule enqueue failed", "schedule", e.Name, "job_id", job.ID, "err", err)
+	}
+}
+
+// active сообщает, не завершено ли задание последнего запуска. Вызывается под s.mu.
+func (s *Scheduler) active(e *Entry) bool {
+	if e.LastJobID == "" {
+		return false
+	}
+	ji, err := s.q.Get(e.LastJobID)
+	return err == nil && !ji.State.Terminal()
+}
+
+// save сохраняет состояние записи в файл. Вызывается под s.mu.
+func (s *Scheduler) save(e *Entry) {
+	if err := s.append(record{Put: e}); err != nil {
+		slog.Error("schedule save failed", "schedule", e.Name, "err", err)
+	}
+}
+
+// append сохраняет запись в файл, если он используется. Вызывается под s.mu.
+func (s *Scheduler) append(rec record) error {
+	if s.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.log.Append(data)
+}
+
+// snapshot сериализует актуальные записи для компактизации файла. Вызывается до публикации s.
+func (s *Scheduler) snapshot() [][]byte {
+	names := make([]string, 0, len(s.entries))
+	for name := range s.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out [][]byte
+	for _, name := range names {
+		data, _ := json.Marshal(record{Put: s.entries[name]})
+		out = append(out, data)
+	}
+	return out
+}
+
+// view возвращает копию записи с вычисленным ближайшим тиком. Вызывается под s.mu.
+func (e *Entry) view() Entry {
+	v := *e
+	v.Next = e.sched.Next(e.LastTick)
+	return v
+}
+
+// JobID выводит идентификатор задания из имени расписания и времени тика.
+func JobID(name string, tick time.Time) string {
+	return name + "-" + tick.UTC().Format("20060102T150405Z")
+}
+
+// dueTicks возвращает не более limit последних тиков расписания в интервале (after, now].
+func dueTicks(sched Schedule, after, now time.Time, limit int) []time.Time {
+	if every, ok := sched.(Every); ok && now.Sub(after) > time.Duration(limit)*every.Period {
+		// Перескакиваем к последним limit тикам, не перебирая весь простой.
+		skip := now.Sub(after)/every.Period - time.Duration(limit)
+		after = after.Add(skip * every.Period)
+	}
+	var out []time.Time
+	for t := sched.Next(after); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		out = append(out, t)
+		if len(out) > limit {
+			out = out[1:]
+		}
+	}
+	return out
+}
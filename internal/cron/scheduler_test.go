@@ -0,0 +1,168 @@
+package cron
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/wal"
+)
+
+// fakeClock — управляемые часы планировщика.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestScheduler(q Queue, clock *fakeClock) *Scheduler {
+	s := New(q)
+	s.now = clock.now
+	return s
+}
+
+// TestPutValidation проверяет проверку определения и значения по умолчанию.
+func TestPutValidation(t *testing.T) {
+	s := New(jobqueue.NewQueue(4))
+	for _, def := range []Definition{
+		{Name: "bad name", Spec: "@hourly"},
+		{Name: "x", Spec: "* * *"},
+		{Name: "x", Spec: "@hourly", Priority: "urgent"},
+		{Name: "x", Spec: "@hourly", Overlap: "never"},
+		{Name: "x", Spec: "@hourly", CatchUp: "some"},
+	} {
+		if _, _, err := s.Put(def); !errors.Is(err, ErrInvalid) {
+			t.Errorf("expected ErrInvalid for %+v, got %v", def, err)
+		}
+	}
+	e, created, err := s.Put(Definition{Name: "x", Spec: "@hourly"})
+	if err != nil || !created || e.Overlap != OverlapSkip || e.CatchUp != CatchUpOnce || e.Priority != jobqueue.PriorityNormal {
+		t.Fatalf("unexpected entry %+v created=%v err=%v", e, created, err)
+	}
+	if _, created, _ = s.Put(Definition{Name: "x", Spec: "@daily"}); created {
+		t.Fatalf("expected update of existing schedule")
+	}
+	if removed, _ := s.Delete("x"); !removed || len(s.List()) != 0 {
+		t.Fatalf("expected schedule removed")
+	}
+}
+
+// TestTickOverlap проверяет постановку по тикам и политики skip, allow и queue.
+func TestTickOverlap(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, policy := range []OverlapPolicy{OverlapSkip, OverlapAllow, OverlapQueue} {
+		q := jobqueue.NewQueue(8)
+		clock := &fakeClock{t: start}
+		s := newTestScheduler(q, clock)
+		_, _, _ = s.Put(Definition{Name: "rep", Spec: "@every 10s", Payload: "p", Overlap: policy})
+
+		clock.t = start.Add(10 * time.Second)
+		s.tick(clock.t)
+		first := JobID("rep", clock.t)
+		if ji, err := q.Get(first); err != nil || ji.State != jobqueue.StateQueued {
+			t.Fatalf("%s: expected first run queued, got %+v err=%v", policy, ji, err)
+		}
+
+		clock.t = start.Add(20 * time.Second)
+		s.tick(clock.t)
+		second := JobID("rep", clock.t)
+		_, err := q.Get(second)
+		e, _ := s.Get("rep")
+		switch policy {
+		case OverlapSkip:
+			if err == nil || e.Skipped != 1 {
+				t.Fatalf("skip: expected tick skipped, entry %+v", e)
+			}
+		case OverlapAllow:
+			if err != nil || e.Runs != 2 {
+				t.Fatalf("allow: expected second run, entry %+v err=%v", e, err)
+			}
+		case OverlapQueue:
+			if err == nil || !e.Pending.Equal(clock.t) {
+				t.Fatalf("queue: expected tick deferred, entry %+v", e)
+			}
+			j, _ := q.Next()
+			q.UpdatesStateDone(j.ID)
+			s.tick(clock.t.Add(time.Second))
+			if _, err := q.Get(second); err != nil {
+				t.Fatalf("queue: expected deferred run after previous finished: %v", err)
+			}
+		}
+	}
+}
+
+// TestCatchUpOnStart проверяет догон пропущенных тиков после рестарта по каждой политике.
+func TestCatchUpOnStart(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		policy CatchUpPolicy
+		runs   int
+	}{{CatchUpNone, 0}, {CatchUpOnce, 1}, {CatchUpAll, 5}} {
+		path := filepath.Join(t.TempDir(), "schedules.wal")
+		q := jobqueue.NewQueue(16)
+		s, err := Open(path, wal.SyncAlways, q)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		s.now = (&fakeClock{t: start}).now
+		_, _, _ = s.Put(Definition{Name: "m", Spec: "* * * * *", CatchUp: c.policy})
+		_ = s.Close()
+
+		s, err = Open(path, wal.SyncAlways, q)
+		if err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+		s.now = (&fakeClock{t: start.Add(5*time.Minute + 30*time.Second)}).now
+		s.Start()
+		s.Stop()
+		_ = s.Close()
+		e, _ := s.Get("m")
+		if e.Runs != c.runs || q.Len() != c.runs {
+			t.Fatalf("%s: expected %d runs, entry %+v queue len %d", c.policy, c.runs, e, q.Len())
+		}
+		if want := start.Add(5 * time.Minute); !e.LastTick.Equal(want) {
+			t.Fatalf("%s: expected last tick %v, got %v", c.policy, want, e.LastTick)
+		}
+	}
+}
+
+// crashQueue ставит задание и имитирует падение процесса до сохранения состояния расписания.
+type crashQueue struct{ *jobqueue.Queue }
+
+func (q crashQueue) Enqueue(job jobqueue.Job) error {
+	err := q.Queue.Enqueue(job)
+	panic(err)
+}
+
+// TestTickRunsOnceAfterCrash проверяет, что тик не повторяется после падения сразу после постановки,
+// даже если очередь перезапускает завершённые задания (DuplicateRerun).
+func TestTickRunsOnceAfterCrash(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "schedules.wal")
+	q, _ := jobqueue.Open(jobqueue.Options{BufferSize: 4, Duplicates: jobqueue.DuplicateRerun})
+	s, err := Open(path, wal.SyncAlways, crashQueue{q})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s.now = (&fakeClock{t: start}).now
+	_, _, _ = s.Put(Definition{Name: "m", Spec: "@every 10s"})
+	func() {
+		defer func() { _ = recover() }()
+		s.tick(start.Add(10 * time.Second))
+	}()
+	_ = s.Close()
+	j, _ := q.Next()
+	q.UpdatesStateDone(j.ID)
+
+	s, err = Open(path, wal.SyncAlways, q)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	s.now = (&fakeClock{t: start.Add(15 * time.Second)}).now
+	s.Start()
+	s.Stop()
+	_ = s.Close()
+	if ji, _ := q.Get(j.ID); ji.State != jobqueue.StateDone || q.Len() != 0 {
+		t.Fatalf("expected tick not to run again after restart, got %s, queue len %d", ji.State, q.Len())
+	}
+}
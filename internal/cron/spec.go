@@ -0,0 +1,210 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule вычисляет моменты срабатывания расписания.
+type Schedule interface {
+	// Next возвращает первый момент срабатывания строго после t
+	// или нулевое время, если срабатываний больше не будет.
+	Next(t time.Time) time.Time
+}
+
+// Every — расписание с фиксированным периодом (@every 30s).
+type Every struct {
+	Period time.Duration
+}
+
+// Next возвращает t + Period.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(e.Period)
+}
+
+// Spec — расписание в формате cron из пяти полей: минута, час, день месяца, месяц, день недели.
+// Каждое поле хранится битовой маской допустимых значений.
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+	// domAny и dowAny отмечают поля, начинающиеся с «*» или «?» (в том числе «*/2»): если ограничены оба поля дня,
+	// день подходит при совпадении любого из них (как в классическом cron).
+	domAny, dowAny bool
+}
+
+// field описывает диапазон значений поля и необязательные имена значений.
+type field struct {
+	name     string
+	min, max int
+	names    []string // names[i] соответствует значению min+i
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// dowField допускает 7 как синоним воскресенья.
+	dowField = field{name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// shortcuts — предопределённые расписания.
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// MinEvery — минимальный период @every: идентификаторы запусков имеют секундную точность.
+const MinEvery = time.Second
+
+// Parse разбирает выражение расписания: пять полей cron (поддерживаются «*», списки,
+// диапазоны, шаги и имена месяцев и дней недели), @every <duration> или сокращения
+// @yearly, @monthly, @weekly, @daily, @hourly.
+func Parse(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid @every duration: %w", err)
+		}
+		if d < MinEvery {
+			return nil, fmt.Errorf("@every period must be at least %s", MinEvery)
+		}
+		return Every{Period: d}, nil
+	}
+	if strings.HasPrefix(expr, "@") {
+		full, ok := shortcuts[strings.ToLower(expr)]
+		if !ok {
+			return nil, fmt.Errorf("unknown shortcut %q", expr)
+		}
+		expr = full
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(parts))
+	}
+	var s Spec
+	var err error
+	if s.minute, err = minuteField.parse(parts[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(parts[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(parts[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(parts[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(parts[4]); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = strings.HasPrefix(parts[2], "*") || strings.HasPrefix(parts[2], "?")
+	s.dowAny = strings.HasPrefix(parts[4], "*") || strings.HasPrefix(parts[4], "?")
+	return s, nil
+}
+
+// parse разбирает поле: список через запятую из «*», значений и диапазонов с необязательным шагом.
+func (f field) parse(s string) (uint64, error) {
+	var mask uint64
+	for _, term := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(term, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, term)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*" || rng == "?":
+		default:
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, term)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+// value разбирает одно значение поля: число в допустимом диапазоне или имя.
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, s)
+	}
+	return n, nil
+}
+
+// Next возвращает первую подходящую минуту строго после t в часовом поясе t.
+// Если за ближайшие пять лет подходящей минуты нет (например, 30 февраля), возвращает нулевое время.
+func (s Spec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches проверяет день месяца и день недели с учётом правила классического cron.
+func (s Spec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
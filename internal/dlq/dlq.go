@@ -0,0 +1,221 @@
+package dlq
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/wal"
+)
+
+// Entry — задание, исчерпавшее все попытки обработки, вместе с историей попыток.
+type Entry struct {
+	Seq       uint64 // порядковый номер попадания в DLQ
+	Job       jobqueue.Job
+	Attempts  []jobqueue.Attempt
+	LastError string
+	FailedAt  time.Time
+}
+
+// Filter задаёт условия выборки записей DLQ.
+type Filter struct {
+	Prefix        string    // префикс идентификатора задания
+	From          time.Time // нижняя граница FailedAt (включительно), нулевое — без ограничения
+	To            time.Time // верхняя граница FailedAt (исключительно), нулевое — без ограничения
+	ErrorContains string    // подстрока последней ошибки
+	After         uint64    // курсор: Seq последней записи предыдущей страницы
+	Limit         int       // максимальный размер страницы, <= 0 — без ограничения
+}
+
+// Match сообщает, удовлетворяет ли запись условиям фильтра (без учёта After и Limit).
+func (f Filter) Match(e Entry) bool {
+	if !strings.HasPrefix(e.Job.ID, f.Prefix) {
+		return false
+	}
+	if !f.From.IsZero() && e.FailedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !e.FailedAt.Before(f.To) {
+		return false
+	}
+	return strings.Contains(e.LastError, f.ErrorContains)
+}
+
+// record — запись файла DLQ: добавление записи либо её удаление.
+type record struct {
+	Add    *Entry `json:"add,omitempty"`
+	Remove string `json:"remove,omitempty"`
+}
+
+// Store — хранилище dead-letter записей, по одной на идентификатор задания.
+// При открытии через Open изменения сохраняются в файл и переживают перезапуск.
+type Store struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+	seq     uint64
+	log     *wal.Log // nil — только в памяти
+}
+
+// New создаёт DLQ в памяти.
+func New() *Store {
+	return &Store{entries: make(map[string]*Entry)}
+}
+
+// Open открывает (или создаёт) DLQ с сохранением в файл path.
+// Файл компактизируется при каждом открытии.
+func Open(path string, sync wal.SyncPolicy) (*Store, error) {
+	l, err := wal.Open(wal.Options{Dir: filepath.Dir(path), Name: filepath.Base(path), Sync: sync})
+	if err != nil {
+		return nil, err
+	}
+	d := New()
+	err = l.Replay(func(data []byte) error {
+		var rec record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		if rec.Add != nil {
+			d.entries[rec.Add.Job.ID] = rec.Add
+			d.seq = max(d.seq, rec.Add.Seq)
+		} else {
+			delete(d.entries, rec.Remove)
+		}
+		return nil
+	})
+	if err == nil {
+		err = l.Rewrite(d.snapshot())
+	}
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	d.log = l
+	return d, nil
+}
+
+// Add помещает задание в DLQ, заменяя предыдущую запись с тем же идентификатором.
+func (d *Store) Add(e Entry) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e.Seq = d.seq + 1
+	if err := d.append(record{Add: &e}); err != nil {
+		return err
+	}
+	d.seq++
+	d.entries[e.Job.ID] = &e
+	return nil
+}
+
+// Get возвращает запись DLQ по идентификатору задания.
+func (d *Store) Get(id string) (Entry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.entries[id]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// List возвращает страницу записей в порядке попадания в DLQ
+// и курсор следующей страницы (0, если страниц больше нет).
+func (d *Store) List(f Filter) ([]Entry, uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Entry
+	for _, e := range d.sorted() {
+		if e.Seq <= f.After || !f.Match(*e) {
+			continue
+		}
+		if f.Limit > 0 && len(out) == f.Limit {
+			return out, out[len(out)-1].Seq
+		}
+		out = append(out, *e)
+	}
+	return out, 0
+}
+
+// Remove удаляет запись из DLQ и сообщает, была ли она.
+func (d *Store) Remove(id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.entries[id]; !ok {
+		return false, nil
+	}
+	if err := d.append(record{Remove: id}); err != nil {
+		return false, err
+	}
+	delete(d.entries, id)
+	return true, nil
+}
+
+// Purge удаляет все записи, удовлетворяющие фильтру, и возвращает их число.
+func (d *Store) Purge(f Filter) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for id, e := range d.entries {
+		if !f.Match(*e) {
+			continue
+		}
+		if err := d.append(record{Remove: id}); err != nil {
+			return n, err
+		}
+		delete(d.entries, id)
+		n++
+	}
+	return n, nil
+}
+
+// Len возвращает число записей в DLQ.
+func (d *Store) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.entries)
+}
+
+// Close закрывает файл DLQ, если он используется.
+func (d *Store) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.log == nil {
+		return nil
+	}
+	return d.log.Close()
+}
+
+// append сохраняет запись в файл, если он используется. Вызывается под d.mu.
+func (d *Store) append(rec record) error {
+	if d.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return d.log.Append(data)
+}
+
+// sorted возвращает записи в порядке Seq. Вызывается под d.mu.
+func (d *Store) sorted() []*Entry {
+	out := make([]*Entry, 0, len(d.entries))
+	for _, e := range d.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].Seq < out[k].Seq })
+	return out
+}
+
+// snapshot сериализует актуальные записи для компактизации файла. Вызывается до публикации d.
+func (d *Store) snapshot() [][]byte {
+	var out [][]byte
+	for _, e := range d.sorted() {
+		data, _ := json.Marshal(record{Add: e})
+		out = append(out, data)
+	}
+	return out
+}
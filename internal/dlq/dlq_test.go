@@ -0,0 +1,78 @@
+package dlq
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kaspContainers/internal/jobqueue"
+	"kaspContainers/internal/wal"
+)
+
+func entry(id, lastErr string, failedAt time.Time) Entry {
+	return Entry{
+		Job:       jobqueue.Job{ID: id, Payload: "p-" + id, MaxRetries: 1},
+		Attempts:  []jobqueue.Attempt{{Number: 1, Error: lastErr}, {Number: 2, Error: lastErr}},
+		LastError: lastErr,
+		FailedAt:  failedAt,
+	}
+}
+
+// TestListFilterAndPurge проверяет порядок, фильтры, курсор и очистку DLQ.
+func TestListFilterAndPurge(t *testing.T) {
+	d := New()
+	base := time.Now()
+	_ = d.Add(entry("a1", "timeout", base))
+	_ = d.Add(entry("b1", "refused", base.Add(time.Second)))
+	_ = d.Add(entry("a2", "refused", base.Add(2*time.Second)))
+
+	page, next := d.List(Filter{Limit: 2})
+	if len(page) != 2 || page[0].Job.ID != "a1" || page[1].Job.ID != "b1" || next == 0 {
+		t.Fatalf("unexpected first page: %+v next=%d", page, next)
+	}
+	page, next = d.List(Filter{Limit: 2, After: next})
+	if len(page) != 1 || page[0].Job.ID != "a2" || next != 0 {
+		t.Fatalf("unexpected second page: %+v next=%d", page, next)
+	}
+	if page, _ = d.List(Filter{Prefix: "a", ErrorContains: "refus"}); len(page) != 1 || page[0].Job.ID != "a2" {
+		t.Fatalf("unexpected filtered page: %+v", page)
+	}
+
+	n, err := d.Purge(Filter{ErrorContains: "refused"})
+	if err != nil || n != 2 || d.Len() != 1 {
+		t.Fatalf("unexpected purge: n=%d err=%v len=%d", n, err, d.Len())
+	}
+	if removed, _ := d.Remove("a1"); !removed || d.Len() != 0 {
+		t.Fatalf("expected a1 removed")
+	}
+}
+
+// TestOpenPersists проверяет, что записи DLQ переживают переоткрытие файла.
+func TestOpenPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.db")
+	d, err := Open(path, wal.SyncNever)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = d.Add(entry("x", "boom", time.Now()))
+	_ = d.Add(entry("y", "boom", time.Now()))
+	_, _ = d.Remove("x")
+	_ = d.Close()
+
+	d, err = Open(path, wal.SyncNever)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer d.Close()
+	e, ok := d.Get("y")
+	if !ok || e.Job.Payload != "p-y" || len(e.Attempts) != 2 {
+		t.Fatalf("unexpected entry after reopen: %+v ok=%v", e, ok)
+	}
+	if _, ok := d.Get("x"); ok {
+		t.Fatalf("expected removed entry to stay removed")
+	}
+	_ = d.Add(entry("z", "boom", time.Now()))
+	if z, _ := d.Get("z"); z.Seq <= e.Seq {
+		t.Fatalf("expected sequence to continue, got %d after %d", z.Seq, e.Seq)
+	}
+}
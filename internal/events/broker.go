@@ -0,0 +1,150 @@
+package events
+
+import "sync"
+
+// Event — событие потока с последовательным идентификатором.
+type Event struct {
+	ID    uint64 // присваивается Broker.Publish, начиная с 1
+	Type  string // имя события SSE
+	JobID string // для фильтрации по заданию
+	State string // для фильтрации по состоянию; пусто — событие без состояния
+	Data  []byte // тело события (JSON)
+}
+
+// DefaultBufferSize — размер кольцевого буфера по умолчанию.
+const DefaultBufferSize = 1024
+
+// subscriberBuffer — запас канала подписчика сверх переданной истории.
+// Подписчик, не успевающий вычитывать события, отключается и может переподключиться с Last-Event-ID.
+const subscriberBuffer = 256
+
+// Broker рассылает события подписчикам и хранит последние события
+// в ограниченном кольцевом буфере для возобновления потока.
+type Broker struct {
+	mu     sync.Mutex
+	ring   []Event
+	start  int // индекс самого старого события в ring
+	n      int // число событий в ring
+	lastID uint64
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// Subscription — подписка на поток событий.
+type Subscription struct {
+	// C получает историю и новые события. Закрывается при Close, при закрытии брокера
+	// и при переполнении, если подписчик не успевает вычитывать события.
+	C <-chan Event
+	// Gap сообщает, что часть событий после запрошенного идентификатора уже вытеснена из буфера.
+	Gap bool
+
+	c      chan Event
+	match  func(Event) bool
+	broker *Broker
+}
+
+// NewBroker создаёт брокер с буфером на size последних событий (size <= 0 — DefaultBufferSize).
+func NewBroker(size int) *Broker {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Broker{ring: make([]Event, size), subs: make(map[*Subscription]struct{})}
+}
+
+// Publish присваивает событию следующий идентификатор, сохраняет его в буфере
+// и рассылает подписчикам. Не блокируется.
+func (b *Broker) Publish(ev Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lastID++
+	ev.ID = b.lastID
+	if b.n < len(b.ring) {
+		b.ring[(b.start+b.n)%len(b.ring)] = ev
+		b.n++
+	} else {
+		b.ring[b.start] = ev
+		b.start = (b.start + 1) % len(b.ring)
+	}
+	for s := range b.subs {
+		if s.match != nil && !s.match(ev) {
+			continue
+		}
+		select {
+		case s.c <- ev:
+		default:
+			b.drop(s)
+		}
+	}
+	return ev
+}
+
+// Subscribe подписывается на события, удовлетворяющие match (nil — все).
+// При after > 0 в канал сначала передаются события из буфера с идентификатором больше after.
+// Если after больше последнего идентификатора (например, после перезапуска сервиса),
+// передаётся весь буфер и подписка отмечается как Gap. Подписка на закрытый брокер
+// возвращает уже закрытый канал.
+func (b *Broker) Subscribe(after uint64, match func(Event) bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var backlog []Event
+	var gap bool
+	if after > 0 && b.n > 0 {
+		oldest := b.ring[b.start].ID
+		if after > b.lastID {
+			after, gap = 0, true
+		} else if after+1 < oldest {
+			gap = true
+		}
+		for i := 0; i < b.n; i++ {
+			ev := b.ring[(b.start+i)%len(b.ring)]
+			if ev.ID > after && (match == nil || match(ev)) {
+				backlog = append(backlog, ev)
+			}
+		}
+	}
+	c := make(chan Event, len(backlog)+subscriberBuffer)
+	for _, ev := range backlog {
+		c <- ev
+	}
+	s := &Subscription{C: c, Gap: gap, c: c, match: match, broker: b}
+	if b.closed {
+		close(c)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+// Close отменяет подписку. Повторные вызовы безопасны.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// LastID возвращает идентификатор последнего опубликованного события.
+func (b *Broker) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Close отключает всех подписчиков; новые подписки сразу получают закрытый канал.
+// Используется при остановке сервиса, чтобы не держать открытыми потоковые соединения.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.drop(s)
+	}
+}
+
+// drop удаляет подписку и закрывает её канал. Вызывается под b.mu.
+func (b *Broker) drop(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.c)
+}
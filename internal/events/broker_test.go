@@ -0,0 +1,88 @@
+package events
+
+import "testing"
+
+// drain вычитывает из канала всё, что в нём уже есть.
+func drain(c <-chan Event) []uint64 {
+	var ids []uint64
+	for {
+		select {
+		case ev, ok := <-c:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, ev.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestBrokerResume(t *testing.T) {
+	b := NewBroker(4)
+	for i := 0; i < 6; i++ {
+		b.Publish(Event{Type: "transition", JobID: "a"})
+	}
+	// В буфере события 3..6.
+	s := b.Subscribe(4, nil)
+	if got := drain(s.C); len(got) != 2 || got[0] != 5 || got[1] != 6 || s.Gap {
+		t.Fatalf("resume from 4: got %v gap=%v", got, s.Gap)
+	}
+	s.Close()
+
+	s = b.Subscribe(1, nil)
+	if got := drain(s.C); len(got) != 4 || got[0] != 3 || !s.Gap {
+		t.Fatalf("resume from evicted id: got %v gap=%v", got, s.Gap)
+	}
+	s.Close()
+
+	s = b.Subscribe(100, nil)
+	if got := drain(s.C); len(got) != 4 || !s.Gap {
+		t.Fatalf("resume from future id: got %v gap=%v", got, s.Gap)
+	}
+	s.Close()
+
+	s = b.Subscribe(0, nil)
+	if got := drain(s.C); len(got) != 0 {
+		t.Fatalf("fresh subscription got backlog %v", got)
+	}
+	b.Publish(Event{Type: "transition"})
+	if got := drain(s.C); len(got) != 1 || got[0] != 7 {
+		t.Fatalf("expected live event 7, got %v", got)
+	}
+	s.Close()
+	s.Close()
+}
+
+func TestBrokerFilterAndClose(t *testing.T) {
+	b := NewBroker(16)
+	s := b.Subscribe(0, func(ev Event) bool { return ev.JobID == "a" })
+	b.Publish(Event{JobID: "b"})
+	b.Publish(Event{JobID: "a"})
+	if got := drain(s.C); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("expected only job a, got %v", got)
+	}
+	b.Close()
+	if _, ok := <-s.C; ok {
+		t.Fatal("expected channel closed after broker close")
+	}
+	s.Close()
+	if _, ok := <-b.Subscribe(0, nil).C; ok {
+		t.Fatal("expected closed channel for subscription on closed broker")
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(8)
+	s := b.Subscribe(0, nil)
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(Event{})
+	}
+	n := 0
+	for range s.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Fatalf("expected %d buffered events before disconnect, got %d", subscriberBuffer, n)
+	}
+}
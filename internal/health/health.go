@@ -0,0 +1,115 @@
+// Package health — реестр проверок состояния сервиса для проб liveness и readiness.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout — ограничение времени одной проверки, если Registry.Timeout не задан.
+const DefaultTimeout = 2 * time.Second
+
+// Status — итог проверки или всей пробы.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// Checker проверяет одну зависимость; nil — зависимость исправна. Проверка должна
+// учитывать отмену ctx.
+type Checker func(ctx context.Context) error
+
+// Result — результат одной проверки.
+type Result struct {
+	Name     string
+	Status   Status
+	Error    string
+	Duration time.Duration
+}
+
+// Report — результат всех проверок реестра.
+type Report struct {
+	Status Status // StatusFail, если не прошла хотя бы одна проверка
+	Checks []Result
+}
+
+// Registry — потокобезопасный набор именованных проверок.
+type Registry struct {
+	Timeout time.Duration // ограничение времени одной проверки; 0 — DefaultTimeout
+
+	mu     sync.Mutex
+	checks map[string]Checker
+}
+
+// NewRegistry создаёт пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Checker)}
+}
+
+// Register добавляет проверку name или заменяет проверку с тем же именем.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = c
+}
+
+// Run выполняет все проверки параллельно и возвращает результаты в порядке имён.
+// Проверка, не уложившаяся в Timeout, считается непройденной.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.checks))
+	checks := make([]Checker, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, r.checks[name])
+	}
+	r.mu.Unlock()
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	rep := Report{Status: StatusOK, Checks: make([]Result, len(names))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rep.Checks[i] = run(ctx, names[i], c, timeout)
+		}()
+	}
+	wg.Wait()
+	for _, res := range rep.Checks {
+		if res.Status != StatusOK {
+			rep.Status = StatusFail
+		}
+	}
+	return rep
+}
+
+// run выполняет одну проверку с ограничением времени.
+func run(ctx context.Context, name string, c Checker, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	res := Result{Name: name, Status: StatusOK, Duration: time.Since(start)}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	return res
+}
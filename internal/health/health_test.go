@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryRun(t *testing.T) {
+	r := NewRegistry()
+	if rep := r.Run(context.Background()); rep.Status != StatusOK || len(rep.Checks) != 0 {
+		t.Fatalf("expected empty registry to pass, got %+v", rep)
+	}
+	r.Register("b", func(context.Context) error { return nil })
+	r.Register("a", func(context.Context) error { return errors.New("down") })
+	rep := r.Run(context.Background())
+	if rep.Status != StatusFail || len(rep.Checks) != 2 || rep.Checks[0].Name != "a" || rep.Checks[0].Error != "down" ||
+		rep.Checks[1].Status != StatusOK {
+		t.Fatalf("unexpected report %+v", rep)
+	}
+	r.Register("a", func(context.Context) error { return nil })
+	if rep := r.Run(context.Background()); rep.Status != StatusOK {
+		t.Fatalf("expected replaced check to pass, got %+v", rep)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Timeout = 10 * time.Millisecond
+	block := make(chan struct{})
+	defer close(block)
+	r.Register("slow", func(context.Context) error { <-block; return nil }) // не учитывает ctx
+	rep := r.Run(context.Background())
+	if rep.Status != StatusFail || rep.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected timed out check to fail, got %+v", rep)
+	}
+}
@@ -0,0 +1,95 @@
+package jobqueue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// DuplicatePolicy определяет, как Enqueue обрабатывает задание с уже известным идентификатором
+// (или ключом идемпотентности).
+type DuplicatePolicy string
+
+const (
+	// DuplicateReject отклоняет любой повтор идентификатора.
+	DuplicateReject DuplicatePolicy = "reject"
+	// DuplicateReplay отклоняет повтор, но точный повтор (тот же payload)
+	// помечается как replay, чтобы вернуть клиенту состояние существующего задания.
+	DuplicateReplay DuplicatePolicy = "replay"
+	// DuplicateRerun как DuplicateReplay, но разрешает повторный запуск
+	// задания, предыдущий запуск которого уже завершён.
+	DuplicateRerun DuplicatePolicy = "rerun"
+)
+
+// ParseDuplicatePolicy проверяет строковое значение политики.
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(s); p {
+	case DuplicateReject, DuplicateReplay, DuplicateRerun:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown duplicate policy %q", s)
+}
+
+// ErrDuplicate — базовая ошибка повторной постановки; конкретика в *DuplicateError.
+var ErrDuplicate = errors.New("duplicate job")
+
+// DuplicateError возвращается Enqueue, если задание с тем же идентификатором
+// или ключом идемпотентности уже существует и политика запрещает новый запуск.
+type DuplicateError struct {
+	Existing JobInfo // сведения о существующем задании
+	Replay   bool    // точный повтор: payload совпадает и политика допускает replay
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate job id=%s state=%s", e.Existing.ID, e.Existing.State)
+}
+
+func (e *DuplicateError) Is(target error) bool { return target == ErrDuplicate }
+
+// hashPayload возвращает SHA-256 payload в шестнадцатеричном виде.
+func hashPayload(payload string) string {
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkDuplicate проверяет, можно ли поставить job с учётом уже известных заданий.
+// rerun разрешает повторный запуск завершённого задания независимо от политики.
+// Вызывается под q.mu.
+func (q *Queue) checkDuplicate(job Job, hash string, rerun bool) error {
+	id := job.ID
+	if job.IdempotencyKey != "" {
+		if keyID, ok := q.keys[job.IdempotencyKey]; ok {
+			id = keyID
+		}
+	}
+	existing, err := q.store.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		if id != job.ID {
+			// ключ указывает на удалённое задание — проверяем сам идентификатор
+			return q.checkDuplicate(Job{ID: job.ID}, hash, rerun)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// задание, отклонённое из-за переполнения, не запускалось — повтор допустим
+	if existing.State == StateFailed && existing.Attempts == 0 && existing.LastError == ErrFull.Error() {
+		return nil
+	}
+	if existing.State.Terminal() && (rerun || q.duplicates == DuplicateRerun) {
+		return nil
+	}
+	replay := q.duplicates != DuplicateReject && existing.PayloadHash == hash
+	return &DuplicateError{Existing: existing, Replay: replay}
+}
+
+// indexKeys восстанавливает соответствие ключей идемпотентности заданиям.
+func (q *Queue) indexKeys(infos []JobInfo) {
+	for _, ji := range infos {
+		if ji.IdempotencyKey != "" {
+			q.keys[ji.IdempotencyKey] = ji.ID
+		}
+	}
+}
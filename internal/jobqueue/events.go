@@ -0,0 +1,46 @@
+package jobqueue
+
+import "time"
+
+// EventType — вид события очереди.
+type EventType string
+
+const (
+	// EventEnqueued — задание принято в очередь (queued или scheduled).
+	EventEnqueued EventType = "enqueued"
+	// EventRejected — постановка отклонена; причина в Event.Err.
+	EventRejected EventType = "rejected"
+	// EventTransition — изменилось состояние задания.
+	EventTransition EventType = "transition"
+)
+
+// Event описывает событие очереди для наблюдателей.
+type Event struct {
+	Type EventType
+	Job  JobInfo // сведения о задании после события; для rejected — только ID и Priority
+	Prev State   // состояние до перехода (для transition)
+	Err  error   // причина отказа (для rejected)
+	Time time.Time
+}
+
+// Observer получает события очереди. Вызывается под мьютексом очереди в порядке событий,
+// поэтому должен быстро возвращать управление и не обращаться к очереди.
+type Observer func(Event)
+
+// Observe регистрирует наблюдателя событий очереди.
+func (q *Queue) Observe(fn Observer) {
+	q.mu.Lock()
+	q.observers = append(q.observers, fn)
+	q.mu.Unlock()
+}
+
+// emit рассылает событие наблюдателям. Вызывается под q.mu.
+func (q *Queue) emit(ev Event) {
+	if len(q.observers) == 0 {
+		return
+	}
+	ev.Time = time.Now()
+	for _, fn := range q.observers {
+		fn(ev)
+	}
+}
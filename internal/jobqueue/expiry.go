@@ -0,0 +1,37 @@
+package jobqueue
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap — min-куча сроков хранения результатов. Запись устаревает, если результат
+// задания с тех пор заменён или удалён; такие записи пропускаются при извлечении.
+type expiryHeap []expiryItem
+
+// expiryItem — срок хранения результата задания id.
+type expiryItem struct {
+	at time.Time
+	id string
+}
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, k int) bool { return h[i].at.Before(h[k].at) }
+func (h expiryHeap) Swap(i, k int)      { h[i], h[k] = h[k], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(expiryItem)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = expiryItem{}
+	*h = old[:n-1]
+	return x
+}
+
+// trackResult добавляет срок хранения результата задания в кучу ExpireResults.
+// Результаты без данных или без срока не отслеживаются. Вызывается под q.mu или до публикации q.
+func (q *Queue) trackResult(id string, r *Result) {
+	if r != nil && r.Data != "" && !r.ExpiresAt.IsZero() {
+		heap.Push(&q.expiries, expiryItem{at: r.ExpiresAt, id: id})
+	}
+}
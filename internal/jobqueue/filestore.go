@@ -0,0 +1,175 @@
+package jobqueue
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"kaspContainers/internal/wal"
+)
+
+// compactMinGarbage — минимальное число устаревших записей файла,
+// после которого FileStore переписывает файл снимком актуальных записей.
+const compactMinGarbage = 1024
+
+// fileRecord — запись файла хранилища: новая версия задания либо его удаление.
+type fileRecord struct {
+	Info   *JobInfo `json:"info,omitempty"`
+	Delete string   `json:"delete,omitempty"`
+}
+
+// FileStore — хранилище в одном файле: каждое изменение дописывается в конец,
+// а при накоплении устаревших записей файл компактизируется. Для чтения
+// используется индекс в памяти, восстанавливаемый из файла при открытии.
+type FileStore struct {
+	mu      sync.Mutex
+	mem     *MemoryStore
+	log     *wal.Log
+	garbage int   // число записей файла, перекрытых более поздними
+	err     error // ошибка последней компактизации, см. Err
+}
+
+// OpenFileStore открывает (или создаёт) файловое хранилище по пути path.
+func OpenFileStore(path string, sync wal.SyncPolicy) (*FileStore, error) {
+	l, err := wal.Open(wal.Options{Dir: filepath.Dir(path), Name: filepath.Base(path), Sync: sync})
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{mem: NewMemoryStore(), log: l}
+	total := 0
+	err = l.Replay(func(data []byte) error {
+		var rec fileRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		total++
+		if rec.Info != nil {
+			_ = s.mem.Put(*rec.Info)
+		} else {
+			_ = s.mem.Delete(rec.Delete)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	s.garbage = total - s.mem.Len()
+	if err := s.maybeCompact(); err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put создаёт или заменяет запись о задании.
+func (s *FileStore) Put(ji JobInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(fileRecord{Info: &ji}); err != nil {
+		return err
+	}
+	if _, err := s.mem.Get(ji.ID); err == nil {
+		s.garbage++
+	}
+	_ = s.mem.Put(ji)
+	s.compact()
+	return nil
+}
+
+// Get возвращает запись о задании из индекса в памяти.
+func (s *FileStore) Get(id string) (JobInfo, error) {
+	return s.mem.Get(id)
+}
+
+// Transition атомарно изменяет запись о задании и дописывает её новую версию в файл.
+func (s *FileStore) Transition(id string, fn func(ji *JobInfo)) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ji, err := s.mem.Get(id)
+	if err != nil {
+		return JobInfo{}, err
+	}
+	seq := ji.Seq
+	fn(&ji)
+	ji.ID, ji.Seq = id, seq
+	if err := s.append(fileRecord{Info: &ji}); err != nil {
+		return JobInfo{}, err
+	}
+	s.garbage++
+	_ = s.mem.Put(ji)
+	s.compact()
+	return ji, nil
+}
+
+// List возвращает страницу записей из индекса в памяти.
+func (s *FileStore) List(f ListFilter) ([]JobInfo, uint64, error) {
+	return s.mem.List(f)
+}
+
+// Delete удаляет запись о задании.
+func (s *FileStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.mem.Get(id); err != nil {
+		return nil
+	}
+	if err := s.append(fileRecord{Delete: id}); err != nil {
+		return err
+	}
+	s.garbage += 2 // удалённая запись и сама запись об удалении
+	_ = s.mem.Delete(id)
+	s.compact()
+	return nil
+}
+
+// Err возвращает ошибку последней компактизации файла; nil — компактизация не требовалась или удалась.
+// Сами изменения к моменту компактизации уже записаны, поэтому её ошибка не делает операцию неуспешной.
+func (s *FileStore) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close закрывает файл хранилища.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
+
+// append дописывает запись в файл. Вызывается под s.mu.
+func (s *FileStore) append(rec fileRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.log.Append(data)
+}
+
+// compact выполняет maybeCompact и запоминает её исход для Err. Вызывается под s.mu.
+func (s *FileStore) compact() {
+	s.err = s.maybeCompact()
+}
+
+// maybeCompact переписывает файл снимком актуальных записей, если устаревших
+// записей накопилось больше, чем актуальных. Вызывается под s.mu.
+func (s *FileStore) maybeCompact() error {
+	if s.garbage < compactMinGarbage || s.garbage < s.mem.Len() {
+		return nil
+	}
+	live, _, _ := s.mem.List(ListFilter{})
+	records := make([][]byte, 0, len(live))
+	for i := range live {
+		data, err := json.Marshal(fileRecord{Info: &live[i]})
+		if err != nil {
+			return err
+		}
+		records = append(records, data)
+	}
+	if err := s.log.Rewrite(records); err != nil {
+		return err
+	}
+	s.garbage = 0
+	return nil
+}
@@ -1,8 +1,11 @@
 package jobqueue
 
 import (
+	"container/heap"
+	"context"
 	"errors"
 	"math/rand"
+	"slices"
 	"sync"
 	"time"
 )
@@ -11,17 +14,81 @@ import (
 type State string
 
 const (
-	StateQueued  State = "queued"
-	StateRunning State = "running"
-	StateDone    State = "done"
-	StateFailed  State = "failed"
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateDone      State = "done"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+	StateScheduled State = "scheduled"
 )
 
+// Terminal сообщает, завершено ли задание в этом состоянии.
+func (s State) Terminal() bool {
+	return s == StateDone || s == StateFailed || s == StateCancelled
+}
+
 // Job представляет задание для обработки.
 type Job struct {
-	ID         string
-	Payload    string
-	MaxRetries int
+	ID             string
+	Payload        string
+	MaxRetries     int
+	IdempotencyKey string        // необязательный ключ идемпотентности клиента
+	Priority       Priority      // пусто — PriorityNormal
+	Type           string        // вид задания; по нему можно приостановить выдачу, см. PauseType
+	RunAt          time.Time     // не раньше этого времени задание станет доступно Next; нулевое — сразу
+	Timeout        time.Duration // таймаут одной попытки обработки; 0 — значение по умолчанию из конфигурации
+	RequestID      string        // идентификатор HTTP-запроса, поставившего задание; для корреляции логов
+	TraceParent    string        // контекст трассы постановки в формате W3C traceparent
+	CallbackURL    string        // URL для уведомления о завершении (done или failed); пусто — без уведомления
+}
+
+// JobInfo описывает текущее состояние задания и историю его обработки.
+type JobInfo struct {
+	ID          string
+	Seq         uint64 // порядковый номер постановки, задаёт стабильный порядок листинга
+	State       State
+	Priority    Priority
+	Type        string
+	RunAt       time.Time // для отложенных заданий
+	Attempts    int
+	EnqueuedAt  time.Time
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	LastError   string
+	PayloadSize int
+	PayloadHash string    // SHA-256 payload, для распознавания точных повторов
+	History     []Attempt // попытки обработки в порядке выполнения
+	// IdempotencyKey — ключ идемпотентности, с которым задание было поставлено.
+	IdempotencyKey string
+	RequestID      string  // идентификатор запроса, поставившего задание
+	Result         *Result // результат успешной обработки; nil — результата нет
+}
+
+// Result — результат обработки задания, хранимый вместе с его состоянием до ExpiresAt.
+// После истечения срока данные удаляются (см. ExpireResults), а ExpiresAt остаётся.
+type Result struct {
+	Data        string
+	ContentType string
+	ExpiresAt   time.Time // нулевое — без срока хранения
+}
+
+// Expired сообщает, что срок хранения результата истёк к моменту now или его данные уже удалены.
+// Пустой результат не сохраняется, поэтому пустые Data означают удалённый результат.
+func (r Result) Expired(now time.Time) bool {
+	return r.Data == "" || (!r.ExpiresAt.IsZero() && !r.ExpiresAt.After(now))
+}
+
+// Attempt описывает одну попытку обработки задания.
+type Attempt struct {
+	Number    int
+	StartedAt time.Time
+	Duration  time.Duration
+	Error     string // пусто для успешной попытки
+	Code      string // машиночитаемый код ошибки попытки
+	// Class — класс ошибки (retryable, permanent, rate_limited), по которому решалось, повторять ли задание.
+	Class      string
+	RetryAfter time.Duration // задержка до следующей попытки; 0 — повтора не было
+	Stack      string        // стек горутины, если попытка завершилась паникой
 }
 
 type item struct {
@@ -29,136 +96,506 @@ type item struct {
 }
 
 type Queue struct {
-	ch        chan item
-	mu        sync.Mutex
-	idToState map[string]State
-	closed    bool
+	mu       sync.Mutex
+	cond     *sync.Cond // сигнализирует о появлении заданий и закрытии; использует mu
+	lanes    []*lane
+	size     int // число ожидающих заданий во всех полосах
+	capacity int
+	store    Store
+	cancels  map[string]context.CancelFunc // функции отмены выполняющихся заданий
+	keys     map[string]string             // ключ идемпотентности → идентификатор задания
+	journal  Journal                       // nil — очередь без журнала
+	seq      uint64
+
+	journalJobs    map[string]Job // незавершённые задания журнала: нужны снимку при компактизации
+	journalRecords int            // записей в журнале
+	journalLive    int            // записей, не перекрытых более поздними (оценка сверху)
+	closed         bool
+
+	timers       timerHeap     // отложенные задания
+	expiries     expiryHeap    // сроки хранения результатов, см. ExpireResults
+	wake         chan struct{} // будит планировщик
+	schedRunning bool
+	schedDone    chan struct{} // закрывается при выходе планировщика
+
+	paused      bool              // общая пауза выдачи, см. Pause
+	pausedLanes map[Priority]bool // приостановленные полосы
+	pausedTypes map[string]bool   // приостановленные виды заданий
+
+	duplicates DuplicatePolicy
+	observers  []Observer
+	waiters    map[string][]chan struct{} // ожидающие завершения задания, см. Wait
+	storeErr   error                      // ошибка последней записи в хранилище или журнал, см. StorageErr
+}
+
+// Options задаёт параметры очереди для Open.
+type Options struct {
+	BufferSize int
+	Store      Store            // nil — MemoryStore
+	Journal    Journal          // nil — очередь без журнала
+	Duplicates DuplicatePolicy  // по умолчанию DuplicateReplay
+	Weights    map[Priority]int // веса полос приоритетов; по умолчанию DefaultWeights
 }
 
 // NewQueue создаёт новую очередь с заданным размером буфера.
 func NewQueue(bufferSize int) *Queue {
-	return &Queue{
-		ch:        make(chan item, bufferSize),
-		idToState: make(map[string]State),
+	return newQueue(Options{BufferSize: bufferSize, Store: NewMemoryStore()})
+}
+
+// newQueue создаёт очередь по параметрам opts; opts.Store должен быть задан.
+func newQueue(opts Options) *Queue {
+	if opts.Duplicates == "" {
+		opts.Duplicates = DuplicateReplay
+	}
+	q := &Queue{
+		lanes:      newLanes(opts.Weights),
+		capacity:   opts.BufferSize,
+		store:      opts.Store,
+		cancels:    make(map[string]context.CancelFunc),
+		keys:       make(map[string]string),
+		wake:       make(chan struct{}, 1),
+		duplicates: opts.Duplicates,
+		waiters:    make(map[string][]chan struct{}),
+
+		journalJobs: make(map[string]Job),
+		pausedLanes: make(map[Priority]bool),
+		pausedTypes: make(map[string]bool),
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Open создаёт очередь с заданными хранилищем и журналом. При наличии журнала
+// состояние восстанавливается из него (см. NewDurableQueue). Незавершённые задания,
+// найденные в хранилище без журнала, помечаются failed: их payload утерян.
+func Open(opts Options) (*Queue, error) {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.Journal != nil {
+		return recoverQueue(opts)
+	}
+	q := newQueue(opts)
+	infos, _, err := opts.Store.List(ListFilter{})
+	if err != nil {
+		return nil, err
 	}
+	q.indexKeys(infos)
+	for _, ji := range infos {
+		q.seq = ji.Seq
+		q.trackResult(ji.ID, ji.Result)
+		if !ji.State.Terminal() {
+			_, err := opts.Store.Transition(ji.ID, func(ji *JobInfo) {
+				ji.State = StateFailed
+				ji.FinishedAt = time.Now()
+				ji.LastError = errLostOnRestart.Error()
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return q, nil
 }
 
 var ErrClosed = errors.New("queue closed")
 var ErrFull = errors.New("queue full")
+var ErrFinished = errors.New("job already finished")
+
+// errLostOnRestart фиксируется как ошибка задания, которое не удалось восстановить после перезапуска.
+var errLostOnRestart = errors.New("lost on restart")
 
-// Enqueue добавляет задание в очередь. Возвращает ошибку, если очередь закрыта или переполнена.
+// Enqueue добавляет задание в очередь. Возвращает ошибку, если очередь закрыта или переполнена,
+// и *DuplicateError (errors.Is(err, ErrDuplicate)), если политика дубликатов запрещает постановку.
 func (q *Queue) Enqueue(job Job) error {
+	return q.enqueue(job, false)
+}
+
+// Rerun ставит задание повторно, даже если политика дубликатов запрещает
+// перезапуск: завершённое задание с тем же идентификатором запускается заново.
+// Активное задание по-прежнему даёт *DuplicateError.
+func (q *Queue) Rerun(job Job) error {
+	return q.enqueue(job, true)
+}
+
+// enqueue реализует Enqueue и Rerun.
+func (q *Queue) enqueue(job Job, rerun bool) (err error) {
 	q.mu.Lock()
+	defer q.mu.Unlock()
+	defer func() {
+		if err != nil {
+			q.emit(Event{Type: EventRejected, Job: JobInfo{ID: job.ID, Priority: job.Priority}, Err: err})
+		}
+	}()
 	if q.closed {
-		q.mu.Unlock()
 		return ErrClosed
 	}
-	q.idToState[job.ID] = StateQueued
-	q.mu.Unlock()
+	if job.Priority == "" {
+		job.Priority = PriorityNormal
+	}
+	hash := hashPayload(job.Payload)
+	if err := q.checkDuplicate(job, hash, rerun); err != nil {
+		return err
+	}
+	now := time.Now()
+	delayed := job.RunAt.After(now)
+	if !delayed {
+		job.RunAt = time.Time{}
+	}
+	if !delayed && q.size >= q.capacity {
+		if _, err := q.store.Get(job.ID); err == nil {
+			// повторная постановка известного задания (Rerun, redrive из DLQ): запись сохраняет историю попыток
+			q.transition(job.ID, failFull)
+			return ErrFull
+		}
+	}
+	ji := JobInfo{
+		ID:             job.ID,
+		Seq:            q.seq + 1,
+		State:          StateQueued,
+		Priority:       job.Priority,
+		Type:           job.Type,
+		RunAt:          job.RunAt,
+		EnqueuedAt:     now,
+		PayloadSize:    len(job.Payload),
+		PayloadHash:    hash,
+		IdempotencyKey: job.IdempotencyKey,
+		RequestID:      job.RequestID,
+	}
+	if delayed {
+		ji.State = StateScheduled
+	}
+	if err := q.record(&ji, &job); err != nil {
+		q.noteStorage(err)
+		return err
+	}
+	if err := q.store.Put(ji); err != nil {
+		q.noteStorage(err)
+		return err
+	}
+	q.noteStorage(q.maybeCompactJournal())
+	q.seq++
+	if job.IdempotencyKey != "" {
+		q.keys[job.IdempotencyKey] = job.ID
+	}
 
-	select {
-	case q.ch <- item{job: job}:
+	// отложенное задание занимает место в очереди, только когда наступит его срок
+	if delayed {
+		q.schedule(item{job: job}, ji.Seq)
+		q.emit(Event{Type: EventEnqueued, Job: ji})
 		return nil
-	default:
-		q.mu.Lock()
-		q.idToState[job.ID] = StateFailed
-		q.mu.Unlock()
+	}
+	if q.size >= q.capacity {
+		q.transition(job.ID, failFull)
 		return ErrFull
 	}
+	q.push(item{job: job})
+	q.emit(Event{Type: EventEnqueued, Job: ji})
+	return nil
+}
+
+// failFull помечает задание, не поместившееся в очередь, как failed.
+func failFull(ji *JobInfo) {
+	ji.State = StateFailed
+	ji.FinishedAt = time.Now()
+	ji.LastError = ErrFull.Error()
 }
 
-// Close закрывает очередь для новых заданий.
+// Close закрывает очередь для новых заданий и останавливает планировщик
+// отложенных заданий; оставшиеся в нём задания возвращает Unscheduled.
 func (q *Queue) Close() {
 	q.mu.Lock()
 	if !q.closed {
 		q.closed = true
-		close(q.ch)
+		q.cond.Broadcast()
+		q.wakeScheduler()
 	}
 	q.mu.Unlock()
 }
 
-// Next блокирующе возвращает следующее задание из очереди.
-// Возвращает ok=false, когда очередь закрыта и опустела.
+// Next блокирующе возвращает следующее задание из очереди, выбирая полосу
+// взвешенно по приоритетам; задания, задержанные паузой, пропускаются до её снятия.
+// Возвращает ok=false, когда очередь закрыта и в ней нет доступных заданий.
 func (q *Queue) Next() (Job, bool) {
-	it, ok := <-q.ch
-	if !ok {
+	return q.NextContext(context.Background())
+}
+
+// NextContext работает как Next, но также возвращает ok=false при отмене ctx.
+func (q *Queue) NextContext(ctx context.Context) (Job, bool) {
+	stop := context.AfterFunc(ctx, func() {
+		q.mu.Lock()
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	})
+	defer stop()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.available() {
+		if q.closed || ctx.Err() != nil {
+			return Job{}, false
+		}
+		q.cond.Wait()
+	}
+	if ctx.Err() != nil {
 		return Job{}, false
 	}
-	return it.job, true
+	return q.pop(q.held).job, true
 }
 
-// UpdatesStateRunning обновляет состояние задания на "выполняется".
-func (q *Queue) UpdatesStateRunning(id string) {
+// Acquire переводит задание в состояние running и регистрирует функцию отмены,
+// которую вызовет Cancel. Возвращает false, если задание отменили, пока оно ждало в очереди.
+func (q *Queue) Acquire(id string, cancel context.CancelFunc) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if ji, err := q.store.Get(id); err == nil && ji.State == StateCancelled {
+		return false
+	}
+	q.cancels[id] = cancel
+	q.transition(id, func(ji *JobInfo) {
+		ji.State = StateRunning
+		ji.StartedAt = time.Now()
+	})
+	return true
+}
+
+// Release снимает регистрацию функции отмены, сделанную Acquire.
+func (q *Queue) Release(id string) {
 	q.mu.Lock()
-	q.idToState[id] = StateRunning
+	delete(q.cancels, id)
 	q.mu.Unlock()
 }
 
-// UpdatesStateDone обновляет состояние задания на "завершено".
-func (q *Queue) UpdatesStateDone(id string) {
+// Cancel отменяет задание и возвращает его состояние на момент вызова.
+// Ожидающее задание сразу переводится в cancelled и удаляется из очереди;
+// выполняющемуся передаётся сигнал отмены, а в cancelled его переводит воркер.
+// Для завершённых заданий возвращает ErrFinished, для неизвестных — ErrNotFound.
+func (q *Queue) Cancel(id string) (State, error) {
 	q.mu.Lock()
-	q.idToState[id] = StateDone
+	defer q.mu.Unlock()
+	ji, err := q.store.Get(id)
+	if err != nil {
+		return "", err
+	}
+	switch ji.State {
+	case StateQueued, StateScheduled:
+		q.remove(id)
+		q.unschedule(id)
+		q.transition(id, func(ji *JobInfo) {
+			ji.State = StateCancelled
+			ji.FinishedAt = time.Now()
+		})
+	case StateRunning:
+		if cancel, ok := q.cancels[id]; ok {
+			cancel()
+		}
+	default:
+		return ji.State, ErrFinished
+	}
+	return ji.State, nil
+}
+
+// transition применяет fn к записи о задании в хранилище, пишет результат в журнал
+// и уведомляет наблюдателей о смене состояния. Вызывается под q.mu.
+// Изменения неизвестных заданий игнорируются.
+func (q *Queue) transition(id string, fn func(ji *JobInfo)) {
+	var prev State
+	ji, err := q.store.Transition(id, func(ji *JobInfo) {
+		prev = ji.State
+		fn(ji)
+	})
+	if err != nil {
+		q.noteStorage(err)
+		return
+	}
+	err = q.record(&ji, nil)
+	if err == nil {
+		err = q.maybeCompactJournal()
+	}
+	q.noteStorage(err)
+	if ji.State != prev {
+		q.emit(Event{Type: EventTransition, Job: ji, Prev: prev})
+		if ji.State.Terminal() {
+			q.wakeWaiters(id)
+		}
+	}
+}
+
+// noteStorage запоминает исход записи в хранилище или журнал: успешная запись сбрасывает
+// прежнюю ошибку. Отсутствие задания ошибкой хранилища не считается. Вызывается под q.mu.
+func (q *Queue) noteStorage(err error) {
+	switch {
+	case err == nil:
+		q.storeErr = nil
+	case !errors.Is(err, ErrNotFound):
+		q.storeErr = err
+	}
+}
+
+// StorageErr возвращает ошибку последней записи в хранилище или журнал, а при её отсутствии —
+// фоновую ошибку хранилища (например, неудачную компактизацию FileStore); nil — хранилище исправно.
+func (q *Queue) StorageErr() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.storeErr != nil {
+		return q.storeErr
+	}
+	if r, ok := q.store.(errReporter); ok {
+		return r.Err()
+	}
+	return nil
+}
+
+// update — блокирующая q.mu обёртка над transition.
+func (q *Queue) update(id string, fn func(ji *JobInfo)) {
+	q.mu.Lock()
+	q.transition(id, fn)
 	q.mu.Unlock()
 }
 
+// UpdatesStateRunning обновляет состояние задания на "выполняется".
+func (q *Queue) UpdatesStateRunning(id string) {
+	q.update(id, func(ji *JobInfo) {
+		ji.State = StateRunning
+		ji.StartedAt = time.Now()
+	})
+}
+
+// UpdatesStateDone обновляет состояние задания на "завершено".
+func (q *Queue) UpdatesStateDone(id string) {
+	q.update(id, func(ji *JobInfo) {
+		ji.State = StateDone
+		ji.FinishedAt = time.Now()
+	})
+}
+
 // UpdatesStateFailed обновляет состояние задания на "неудачно".
 func (q *Queue) UpdatesStateFailed(id string) {
+	q.update(id, func(ji *JobInfo) {
+		ji.State = StateFailed
+		ji.FinishedAt = time.Now()
+	})
+}
+
+// UpdatesStateCancelled обновляет состояние задания на "отменено".
+func (q *Queue) UpdatesStateCancelled(id string) {
+	q.update(id, func(ji *JobInfo) {
+		ji.State = StateCancelled
+		ji.FinishedAt = time.Now()
+	})
+}
+
+// UpdatesAttempt учитывает очередную попытку обработки задания и добавляет её в историю.
+// Непустой at.Error сохраняется как последняя ошибка задания.
+func (q *Queue) UpdatesAttempt(id string, at Attempt) {
+	q.update(id, func(ji *JobInfo) {
+		ji.Attempts++
+		if at.Number == 0 {
+			at.Number = ji.Attempts
+		}
+		if at.Error != "" {
+			ji.LastError = at.Error
+		}
+		// Clip гарантирует копирование: ранее выданные копии JobInfo не разделяют массив с новой историей
+		ji.History = append(slices.Clip(ji.History), at)
+	})
+}
+
+// UpdatesResult сохраняет результат обработки задания.
+func (q *Queue) UpdatesResult(id string, r Result) {
 	q.mu.Lock()
-	q.idToState[id] = StateFailed
-	q.mu.Unlock()
+	defer q.mu.Unlock()
+	q.transition(id, func(ji *JobInfo) {
+		ji.Result = &r
+	})
+	q.trackResult(id, &r)
 }
 
-// StatesSnapshot возвращает снимок всех состояний заданий.
-func (q *Queue) StatesSnapshot() map[string]State {
+// ExpireResults удаляет данные результатов, срок хранения которых истёк к моменту now,
+// и возвращает их число. Просматриваются только истёкшие сроки из кучи, а не всё хранилище.
+func (q *Queue) ExpireResults(now time.Time) int {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	copy := make(map[string]State, len(q.idToState))
-	for k, v := range q.idToState {
-		copy[k] = v
+	n := 0
+	for len(q.expiries) > 0 && !q.expiries[0].at.After(now) {
+		e := heap.Pop(&q.expiries).(expiryItem)
+		ji, err := q.store.Get(e.id)
+		if err != nil || ji.Result == nil || ji.Result.Data == "" || !ji.Result.ExpiresAt.Equal(e.at) {
+			continue // результат заменён или уже удалён
+		}
+		q.transition(e.id, func(ji *JobInfo) {
+			ji.Result = &Result{ExpiresAt: ji.Result.ExpiresAt}
+		})
+		n++
+	}
+	return n
+}
+
+// Get возвращает сведения о задании или ErrNotFound.
+func (q *Queue) Get(id string) (JobInfo, error) {
+	return q.store.Get(id)
+}
+
+// List возвращает страницу заданий, упорядоченных по времени постановки,
+// и курсор следующей страницы (0, если страниц больше нет).
+func (q *Queue) List(f ListFilter) ([]JobInfo, uint64, error) {
+	return q.store.List(f)
+}
+
+// StatesSnapshot возвращает снимок всех состояний заданий. Просматривает всё хранилище,
+// поэтому предназначен для тестов и отладки, а не для периодического вызова.
+func (q *Queue) StatesSnapshot() map[string]State {
+	infos, _, _ := q.store.List(ListFilter{})
+	copy := make(map[string]State, len(infos))
+	for _, ji := range infos {
+		copy[ji.ID] = ji.State
 	}
 	return copy
 }
 
-// WorkerLoop обрабатывает задания из очереди до закрытия канала или завершения контекста done.
+// WorkerLoop обрабатывает задания из очереди до её закрытия или закрытия канала done.
 // simulateProcess имитирует обработку задачи и возвращает ok=true при успехе, иначе false.
 // Устаревший метод, используется только в тестах.
 func WorkerLoop(done <-chan struct{}, q *Queue, simulateProcess func(Job) bool) {
-	for {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
 		select {
 		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		job, ok := q.NextContext(ctx)
+		if !ok {
 			return
-		case it, ok := <-q.ch:
-			if !ok {
-				return
+		}
+		q.UpdatesStateRunning(job.ID)
+
+		// ретраи с экспоненциальным бэкофом и джиттером
+		var attempt int
+		maxAttempts := job.MaxRetries + 1
+		for {
+			if simulateProcess(job) {
+				q.UpdatesStateDone(job.ID)
+				break
+			}
+			attempt++
+			if attempt >= maxAttempts {
+				q.UpdatesStateFailed(job.ID)
+				break
 			}
-			job := it.job
-			q.UpdatesStateRunning(job.ID)
-
-			// ретраи с экспоненциальным бэкофом и джиттером
-			var attempt int
-			maxAttempts := job.MaxRetries + 1
-			for {
-				if simulateProcess(job) {
-					q.UpdatesStateDone(job.ID)
-					break
-				}
-				attempt++
-				if attempt >= maxAttempts {
-					q.UpdatesStateFailed(job.ID)
-					break
-				}
-				// экспоненциальный бэкофф 50..100ms * 2^(attempt-1) с джиттером
-				baseMs := 50 + rand.Intn(51) // 50..100
-				backoff := time.Duration(baseMs) * time.Millisecond
-				for i := 1; i < attempt; i++ {
-					backoff *= 2
-				}
-				jitter := time.Duration(rand.Intn(50)) * time.Millisecond
-				select {
-				case <-done:
-					return
-				case <-time.After(backoff + jitter):
-				}
+			// экспоненциальный бэкофф 50..100ms * 2^(attempt-1) с джиттером
+			baseMs := 50 + rand.Intn(51) // 50..100
+			backoff := time.Duration(baseMs) * time.Millisecond
+			for i := 1; i < attempt; i++ {
+				backoff *= 2
+			}
+			jitter := time.Duration(rand.Intn(50)) * time.Millisecond
+			select {
+			case <-done:
+				return
+			case <-time.After(backoff + jitter):
 			}
 		}
 	}
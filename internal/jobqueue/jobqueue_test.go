@@ -1,6 +1,10 @@
 package jobqueue
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -105,3 +109,386 @@ func TestRetriesWithBackoff(t *testing.T) {
 		q.Close()
 	}
 }
+
+// TestListPaginationAndFilters проверяет стабильный порядок, фильтры и курсор листинга.
+func TestListPaginationAndFilters(t *testing.T) {
+	q := NewQueue(16)
+	defer q.Close()
+
+	for _, id := range []string{"a1", "b1", "a2", "a3", "b2"} {
+		if err := q.Enqueue(Job{ID: id}); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+	q.UpdatesStateFailed("a2")
+
+	page, next, _ := q.List(ListFilter{Prefix: "a", Limit: 2})
+	if len(page) != 2 || page[0].ID != "a1" || page[1].ID != "a2" || next == 0 {
+		t.Fatalf("unexpected first page: %+v next=%d", page, next)
+	}
+	page, next, _ = q.List(ListFilter{Prefix: "a", Limit: 2, After: next})
+	if len(page) != 1 || page[0].ID != "a3" || next != 0 {
+		t.Fatalf("unexpected second page: %+v next=%d", page, next)
+	}
+
+	page, _, _ = q.List(ListFilter{State: StateFailed})
+	if len(page) != 1 || page[0].ID != "a2" {
+		t.Fatalf("unexpected state filter result: %+v", page)
+	}
+
+	page, _, _ = q.List(ListFilter{To: time.Now().Add(-time.Hour)})
+	if len(page) != 0 {
+		t.Fatalf("expected empty result for past range, got %+v", page)
+	}
+}
+
+// TestCancel проверяет отмену ожидающего и выполняющегося заданий.
+func TestCancel(t *testing.T) {
+	q := NewQueue(4)
+	defer q.Close()
+
+	_ = q.Enqueue(Job{ID: "queued"})
+	_ = q.Enqueue(Job{ID: "running"})
+	if prev, err := q.Cancel("queued"); err != nil || prev != StateQueued {
+		t.Fatalf("cancel queued: prev=%v err=%v", prev, err)
+	}
+	job, ok := q.Next()
+	if !ok || job.ID != "running" {
+		t.Fatalf("expected cancelled job skipped, got %+v", job)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if !q.Acquire(job.ID, cancel) {
+		t.Fatalf("acquire failed")
+	}
+	if prev, err := q.Cancel("running"); err != nil || prev != StateRunning {
+		t.Fatalf("cancel running: prev=%v err=%v", prev, err)
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("expected running job context cancelled")
+	}
+	q.Release(job.ID)
+	q.UpdatesStateCancelled(job.ID)
+
+	if _, err := q.Cancel("running"); err != ErrFinished {
+		t.Fatalf("expected ErrFinished, got %v", err)
+	}
+	if _, err := q.Cancel("missing"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// TestDuplicatePolicies проверяет обработку повторной постановки по каждой политике.
+func TestDuplicatePolicies(t *testing.T) {
+	newQ := func(p DuplicatePolicy) *Queue {
+		q, err := Open(Options{BufferSize: 8, Duplicates: p})
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		return q
+	}
+	dupOf := func(err error) *DuplicateError {
+		var dup *DuplicateError
+		if !errors.As(err, &dup) {
+			t.Fatalf("expected DuplicateError, got %v", err)
+		}
+		return dup
+	}
+
+	q := newQ(DuplicateReplay)
+	_ = q.Enqueue(Job{ID: "a", Payload: "p"})
+	if dup := dupOf(q.Enqueue(Job{ID: "a", Payload: "p"})); !dup.Replay || dup.Existing.State != StateQueued {
+		t.Fatalf("expected replay of queued job, got %+v", dup)
+	}
+	if dup := dupOf(q.Enqueue(Job{ID: "a", Payload: "other"})); dup.Replay {
+		t.Fatalf("expected conflict for different payload")
+	}
+	q.UpdatesStateDone("a")
+	if dup := dupOf(q.Enqueue(Job{ID: "a", Payload: "p"})); !dup.Replay {
+		t.Fatalf("expected replay of done job under replay policy")
+	}
+	if err := q.Rerun(Job{ID: "a", Payload: "p"}); err != nil {
+		t.Fatalf("rerun of finished job: %v", err)
+	}
+
+	q = newQ(DuplicateReject)
+	_ = q.Enqueue(Job{ID: "a", Payload: "p"})
+	if dup := dupOf(q.Enqueue(Job{ID: "a", Payload: "p"})); dup.Replay {
+		t.Fatalf("reject policy must not replay")
+	}
+
+	q = newQ(DuplicateRerun)
+	_ = q.Enqueue(Job{ID: "a", Payload: "p"})
+	dupOf(q.Enqueue(Job{ID: "a", Payload: "p"}))
+	q.UpdatesStateFailed("a")
+	if err := q.Enqueue(Job{ID: "a", Payload: "p"}); err != nil {
+		t.Fatalf("rerun policy must allow finished job, got %v", err)
+	}
+	if st := q.StatesSnapshot()["a"]; st != StateQueued {
+		t.Fatalf("expected rerun job queued, got %v", st)
+	}
+}
+
+// TestIdempotencyKey проверяет, что ключ идемпотентности распознаёт повтор с другим id.
+func TestIdempotencyKey(t *testing.T) {
+	q := NewQueue(4)
+	defer q.Close()
+	_ = q.Enqueue(Job{ID: "a", Payload: "p", IdempotencyKey: "k"})
+	var dup *DuplicateError
+	if err := q.Enqueue(Job{ID: "b", Payload: "p", IdempotencyKey: "k"}); !errors.As(err, &dup) || dup.Existing.ID != "a" {
+		t.Fatalf("expected duplicate of a by key, got %v", err)
+	}
+}
+
+// TestEnqueueAfterFullIsAllowed проверяет, что отклонённое из-за переполнения задание можно поставить снова.
+func TestEnqueueAfterFullIsAllowed(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	_ = q.Enqueue(Job{ID: "a"})
+	if err := q.Enqueue(Job{ID: "b"}); err != ErrFull {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+	_, _ = q.Next()
+	if err := q.Enqueue(Job{ID: "b"}); err != nil {
+		t.Fatalf("expected retry after full to be accepted, got %v", err)
+	}
+}
+
+// TestRerunOnFullKeepsHistory проверяет, что отказ в повторной постановке из-за переполнения
+// не затирает историю прежних попыток задания.
+func TestRerunOnFullKeepsHistory(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	_ = q.Enqueue(Job{ID: "a"})
+	job, _ := q.Next()
+	q.UpdatesStateRunning(job.ID)
+	q.UpdatesAttempt(job.ID, Attempt{Error: "boom"})
+	q.UpdatesStateFailed(job.ID)
+	before, _ := q.Get("a")
+	_ = q.Enqueue(Job{ID: "b"})
+	if err := q.Rerun(Job{ID: "a"}); err != ErrFull {
+		t.Fatalf("expected ErrFull, got %v", err)
+	}
+	ji, _ := q.Get("a")
+	if ji.Seq != before.Seq || ji.Attempts != 1 || len(ji.History) != 1 || ji.State != StateFailed || ji.LastError != ErrFull.Error() {
+		t.Fatalf("expected prior history kept, got %+v", ji)
+	}
+}
+
+// TestWeightedPriorities проверяет, что высокий приоритет выдаётся чаще,
+// а низкий не голодает.
+func TestWeightedPriorities(t *testing.T) {
+	q, _ := Open(Options{BufferSize: 64, Weights: map[Priority]int{PriorityHigh: 3, PriorityNormal: 1, PriorityLow: 1}})
+	defer q.Close()
+	for i := 0; i < 10; i++ {
+		for _, p := range Priorities {
+			if err := q.Enqueue(Job{ID: string(p) + strconv.Itoa(i), Priority: p}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+	}
+	if d := q.Depths(); d[PriorityHigh] != 10 || d[PriorityLow] != 10 || q.Len() != 30 {
+		t.Fatalf("unexpected depths: %v", d)
+	}
+
+	counts := map[Priority]int{}
+	for i := 0; i < 10; i++ {
+		job, _ := q.Next()
+		counts[job.Priority]++
+	}
+	if counts[PriorityHigh] != 6 || counts[PriorityNormal] != 2 || counts[PriorityLow] != 2 {
+		t.Fatalf("unexpected distribution of first 10 jobs: %v", counts)
+	}
+
+	// порядок внутри полосы — FIFO
+	q2 := NewQueue(4)
+	defer q2.Close()
+	_ = q2.Enqueue(Job{ID: "l1", Priority: PriorityLow})
+	_ = q2.Enqueue(Job{ID: "l2", Priority: PriorityLow})
+	if j, _ := q2.Next(); j.ID != "l1" {
+		t.Fatalf("expected FIFO within lane, got %s", j.ID)
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights("high=10, low=2")
+	if err != nil || w[PriorityHigh] != 10 || w[PriorityLow] != 2 {
+		t.Fatalf("unexpected weights: %v err=%v", w, err)
+	}
+	if _, err := ParseWeights("urgent=1"); err == nil {
+		t.Fatalf("expected error for unknown priority")
+	}
+	if _, err := ParseWeights("high=0"); err == nil {
+		t.Fatalf("expected error for non-positive weight")
+	}
+}
+
+// TestNextContextCancel проверяет, что NextContext возвращается при отмене контекста.
+func TestNextContextCancel(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, ok := q.NextContext(ctx); ok {
+		t.Fatalf("expected ok=false on context cancel")
+	}
+}
+
+// TestTakePending проверяет извлечение ожидающих заданий при остановке.
+func TestTakePending(t *testing.T) {
+	q := NewQueue(4)
+	_ = q.Enqueue(Job{ID: "a", Priority: PriorityLow})
+	_ = q.Enqueue(Job{ID: "b", Priority: PriorityHigh})
+	q.Close()
+	jobs := q.TakePending()
+	if len(jobs) != 2 || jobs[0].ID != "b" || q.Len() != 0 {
+		t.Fatalf("unexpected pending jobs %+v, len %d", jobs, q.Len())
+	}
+	if ji, _ := q.Get("a"); ji.State != StateQueued {
+		t.Fatalf("expected state kept, got %s", ji.State)
+	}
+	if _, ok := q.Next(); ok {
+		t.Fatal("expected closed empty queue")
+	}
+}
+
+// TestObserveEvents проверяет события постановки, отказа и смены состояния.
+func TestObserveEvents(t *testing.T) {
+	q := NewQueue(1)
+	var got []string
+	q.Observe(func(ev Event) {
+		s := string(ev.Type) + ":" + ev.Job.ID
+		if ev.Type == EventTransition {
+			s += ":" + string(ev.Prev) + "->" + string(ev.Job.State)
+		}
+		got = append(got, s)
+	})
+	_ = q.Enqueue(Job{ID: "a"})
+	_ = q.Enqueue(Job{ID: "b"})
+	j, _ := q.Next()
+	q.Acquire(j.ID, func() {})
+	q.UpdatesAttempt(j.ID, Attempt{})
+	q.UpdatesStateDone(j.ID)
+	want := []string{
+		"enqueued:a",
+		"transition:b:queued->failed",
+		"rejected:b",
+		"transition:a:queued->running",
+		"transition:a:running->done",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected events:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+// TestWait проверяет ожидание завершения задания, таймаут и неизвестный идентификатор.
+func TestWait(t *testing.T) {
+	q := NewQueue(4)
+	defer q.Close()
+	if _, err := q.Wait(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	_ = q.Enqueue(Job{ID: "w"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	ji, err := q.Wait(ctx, "w")
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) || ji.State != StateQueued {
+		t.Fatalf("expected timeout with queued state, got %s %v", ji.State, err)
+	}
+	if len(q.waiters) != 0 {
+		t.Fatalf("expected waiter removed after timeout, got %d", len(q.waiters))
+	}
+
+	results := make(chan JobInfo, 2)
+	for range 2 {
+		go func() {
+			ji, _ := q.Wait(context.Background(), "w")
+			results <- ji
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	q.UpdatesStateRunning("w")
+	q.UpdatesStateDone("w")
+	for range 2 {
+		select {
+		case ji := <-results:
+			if ji.State != StateDone {
+				t.Fatalf("expected done, got %s", ji.State)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("waiter not woken")
+		}
+	}
+	if ji, err := q.Wait(context.Background(), "w"); err != nil || ji.State != StateDone {
+		t.Fatalf("expected immediate return for finished job, got %s %v", ji.State, err)
+	}
+}
+
+// TestExpireResults проверяет удаление данных результатов с истёкшим сроком хранения.
+func TestExpireResults(t *testing.T) {
+	q := NewQueue(4)
+	defer q.Close()
+	now := time.Now()
+	for id, exp := range map[string]time.Time{"old": now.Add(-time.Second), "fresh": now.Add(time.Hour), "forever": {}} {
+		_ = q.Enqueue(Job{ID: id})
+		q.UpdatesResult(id, Result{Data: "r-" + id, ExpiresAt: exp})
+	}
+	_ = q.Enqueue(Job{ID: "replaced"})
+	q.UpdatesResult("replaced", Result{Data: "stale", ExpiresAt: now.Add(-time.Second)})
+	q.UpdatesResult("replaced", Result{Data: "r-replaced", ExpiresAt: now.Add(time.Hour)})
+	if n := q.ExpireResults(now); n != 1 {
+		t.Fatalf("expected 1 expired result, got %d", n)
+	}
+	if ji, _ := q.Get("old"); ji.Result == nil || ji.Result.Data != "" || ji.Result.ExpiresAt.IsZero() {
+		t.Fatalf("expected expired result without data, got %+v", ji.Result)
+	}
+	for _, id := range []string{"fresh", "forever", "replaced"} {
+		if ji, _ := q.Get(id); ji.Result == nil || ji.Result.Data != "r-"+id {
+			t.Fatalf("expected %s result kept, got %+v", id, ji.Result)
+		}
+	}
+	if n := q.ExpireResults(now.Add(2 * time.Hour)); n != 2 {
+		t.Fatalf("expected fresh and replaced results expired later, got %d", n)
+	}
+}
+
+// TestPause проверяет, что паузы задерживают выдачу заданий, но не постановку.
+func TestPause(t *testing.T) {
+	q := NewQueue(8)
+	q.Pause()
+	q.PauseLane(PriorityLow)
+	q.PauseType("emails")
+	_ = q.Enqueue(Job{ID: "low", Priority: PriorityLow})
+	_ = q.Enqueue(Job{ID: "mail", Type: "emails"})
+	_ = q.Enqueue(Job{ID: "report", Type: "reports"})
+	if st := q.Paused(); !st.All || len(st.Lanes) != 1 || st.Lanes[0] != PriorityLow || len(st.Types) != 1 {
+		t.Fatalf("unexpected pause state %+v", st)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, ok := q.NextContext(ctx); ok || q.Available() != 0 || q.Len() != 3 {
+		t.Fatalf("expected paused queue to hold jobs, available %d len %d", q.Available(), q.Len())
+	}
+	got := make(chan Job, 1)
+	go func() {
+		j, _ := q.Next()
+		got <- j
+	}()
+	q.Resume()
+	if j := <-got; j.ID != "report" {
+		t.Fatalf("expected only unpaused type to be handed out, got %q", j.ID)
+	}
+	q.ResumeType("emails")
+	if j, _ := q.Next(); j.ID != "mail" {
+		t.Fatalf("expected resumed type, got %q", j.ID)
+	}
+	q.Close()
+	if _, ok := q.Next(); ok {
+		t.Fatal("expected closed queue with only paused jobs to stop workers")
+	}
+	if jobs := q.TakePending(); len(jobs) != 1 || jobs[0].ID != "low" {
+		t.Fatalf("expected paused job to be taken on shutdown, got %+v", jobs)
+	}
+}
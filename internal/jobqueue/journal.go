@@ -0,0 +1,172 @@
+package jobqueue
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Journal — журнал операций очереди, позволяющий восстановить задания после перезапуска.
+// Реализуется, например, wal.Log.
+type Journal interface {
+	Append(data []byte) error
+	Replay(fn func(data []byte) error) error
+	Rewrite(records [][]byte) error
+}
+
+// journalRecord — запись журнала: актуальные сведения о задании и, для
+// незавершённых заданий, само задание, чтобы его можно было поставить повторно.
+type journalRecord struct {
+	Info JobInfo `json:"info"`
+	Job  *Job    `json:"job,omitempty"`
+}
+
+// record пишет в журнал текущее состояние задания. Вызывается под q.mu.
+// Ошибки записи переходов состояний вызывающие игнорируют: при восстановлении
+// задание окажется в предыдущем состоянии и будет обработано повторно (at-least-once).
+func (q *Queue) record(ji *JobInfo, job *Job) error {
+	if q.journal == nil {
+		return nil
+	}
+	data, err := json.Marshal(journalRecord{Info: *ji, Job: job})
+	if err != nil {
+		return err
+	}
+	if err := q.journal.Append(data); err != nil {
+		return err
+	}
+	q.journalRecords++
+	if job != nil {
+		q.journalJobs[ji.ID] = *job
+		q.journalLive++
+	}
+	if ji.State.Terminal() {
+		delete(q.journalJobs, ji.ID)
+	}
+	return nil
+}
+
+// maybeCompactJournal переписывает журнал снимком заданий хранилища, если устаревших
+// записей накопилось больше, чем актуальных, — как FileStore.maybeCompact.
+// Вызывается под q.mu после того, как изменение попало и в журнал, и в хранилище.
+func (q *Queue) maybeCompactJournal() error {
+	garbage := q.journalRecords - q.journalLive
+	if q.journal == nil || garbage < compactMinGarbage || garbage < q.journalLive {
+		return nil
+	}
+	infos, _, err := q.store.List(ListFilter{})
+	if err != nil {
+		return err
+	}
+	snapshot := make([][]byte, 0, len(infos))
+	for i := range infos {
+		var job *Job
+		if jb, ok := q.journalJobs[infos[i].ID]; ok {
+			job = &jb
+		}
+		data, err := json.Marshal(journalRecord{Info: infos[i], Job: job})
+		if err != nil {
+			return err
+		}
+		snapshot = append(snapshot, data)
+	}
+	if err := q.journal.Rewrite(snapshot); err != nil {
+		return err
+	}
+	q.journalRecords, q.journalLive = len(snapshot), len(snapshot)
+	return nil
+}
+
+// NewDurableQueue создаёт очередь в памяти, восстанавливая её состояние из журнала j.
+func NewDurableQueue(bufferSize int, j Journal) (*Queue, error) {
+	return Open(Options{BufferSize: bufferSize, Journal: j})
+}
+
+// recoverQueue восстанавливает очередь из журнала opts.Journal.
+// Задания в состоянии queued и прерванные в состоянии running ставятся в очередь
+// повторно в исходном порядке, отложенные (scheduled) снова ждут своего срока; если их больше, чем BufferSize, буфер расширяется,
+// чтобы не потерять ни одного задания. Журнал считается источником истины для
+// известных ему заданий и после восстановления компактизируется; во время работы журнал
+// компактизируется, когда устаревших записей становится больше, чем актуальных.
+func recoverQueue(opts Options) (*Queue, error) {
+	j := opts.Journal
+	infos := make(map[string]JobInfo)
+	jobs := make(map[string]Job)
+	err := j.Replay(func(data []byte) error {
+		var rec journalRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		infos[rec.Info.ID] = rec.Info
+		if rec.Job != nil {
+			jobs[rec.Info.ID] = *rec.Job
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]JobInfo, 0, len(infos))
+	for _, ji := range infos {
+		ordered = append(ordered, ji)
+	}
+	sort.Slice(ordered, func(i, k int) bool { return ordered[i].Seq < ordered[k].Seq })
+
+	var pending, delayed []Job
+	snapshot := make([][]byte, 0, len(ordered))
+	for i := range ordered {
+		ji := &ordered[i]
+		var job *Job
+		if !ji.State.Terminal() {
+			if jb, ok := jobs[ji.ID]; ok {
+				job = &jb
+				if ji.State == StateScheduled {
+					delayed = append(delayed, jb)
+				} else {
+					ji.State = StateQueued
+					ji.StartedAt = time.Time{}
+					pending = append(pending, jb)
+				}
+			}
+		}
+		data, err := json.Marshal(journalRecord{Info: *ji, Job: job})
+		if err != nil {
+			return nil, err
+		}
+		snapshot = append(snapshot, data)
+		if err := opts.Store.Put(*ji); err != nil {
+			return nil, err
+		}
+	}
+	if err := j.Rewrite(snapshot); err != nil {
+		return nil, err
+	}
+
+	opts.BufferSize = max(opts.BufferSize, len(pending))
+	q := newQueue(opts)
+	q.indexKeys(ordered)
+	for i := range ordered {
+		q.trackResult(ordered[i].ID, ordered[i].Result)
+	}
+	if n := len(ordered); n > 0 {
+		q.seq = ordered[n-1].Seq
+	}
+	if last, _, err := opts.Store.List(ListFilter{}); err == nil && len(last) > 0 {
+		q.seq = max(q.seq, last[len(last)-1].Seq)
+	}
+	q.journalRecords, q.journalLive = len(snapshot), len(snapshot)
+	q.mu.Lock()
+	for _, jb := range pending {
+		q.push(item{job: jb})
+		q.journalJobs[jb.ID] = jb
+	}
+	for _, jb := range delayed {
+		q.journalJobs[jb.ID] = jb
+		ji, _ := opts.Store.Get(jb.ID)
+		q.schedule(item{job: jb}, ji.Seq)
+	}
+	q.mu.Unlock()
+	q.journal = j
+	return q, nil
+}
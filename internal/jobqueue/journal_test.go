@@ -0,0 +1,141 @@
+package jobqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kaspContainers/internal/wal"
+)
+
+// TestDurableQueueRecovers проверяет, что после перезапуска задания в состоянии
+// queued и прерванные running возвращаются в очередь, а завершённые — нет.
+func TestDurableQueueRecovers(t *testing.T) {
+	dir := t.TempDir()
+	l, err := wal.Open(wal.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("wal open: %v", err)
+	}
+	q, err := NewDurableQueue(4, l)
+	if err != nil {
+		t.Fatalf("new durable queue: %v", err)
+	}
+	for _, id := range []string{"done", "running", "queued"} {
+		if err := q.Enqueue(Job{ID: id, Payload: "p-" + id, MaxRetries: 2}); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+	if err := q.Enqueue(Job{ID: "delayed", RunAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("enqueue delayed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		job, _ := q.Next()
+		q.UpdatesStateRunning(job.ID)
+	}
+	q.UpdatesAttempt("done", Attempt{Number: 1})
+	q.UpdatesResult("done", Result{Data: `{"ok":true}`, ContentType: "application/json"})
+	q.UpdatesStateDone("done")
+	_ = l.Close() // имитируем падение без Close очереди
+
+	l, err = wal.Open(wal.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("wal reopen: %v", err)
+	}
+	defer l.Close()
+	q, err = NewDurableQueue(1, l)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	defer q.Close()
+
+	st := q.StatesSnapshot()
+	if st["done"] != StateDone || st["running"] != StateQueued || st["queued"] != StateQueued || st["delayed"] != StateScheduled {
+		t.Fatalf("unexpected recovered states: %v", st)
+	}
+	if ji, _ := q.Get("done"); ji.Attempts != 1 || ji.Result == nil || ji.Result.Data != `{"ok":true}` {
+		t.Fatalf("expected attempts and result restored, got %+v", ji)
+	}
+	for _, want := range []string{"running", "queued"} {
+		job, ok := q.Next()
+		if !ok || job.ID != want || job.Payload != "p-"+want || job.MaxRetries != 2 {
+			t.Fatalf("expected %s requeued, got %+v ok=%v", want, job, ok)
+		}
+	}
+	if err := q.Enqueue(Job{ID: "after"}); err != nil {
+		t.Fatalf("enqueue after recovery: %v", err)
+	}
+	if left := q.Unscheduled(); len(left) != 1 || left[0].ID != "delayed" {
+		t.Fatalf("expected delayed job rescheduled, got %+v", left)
+	}
+	if ji, _ := q.Get("after"); ji.Seq <= 4 {
+		t.Fatalf("expected sequence to continue after recovery, got %d", ji.Seq)
+	}
+}
+
+// TestStorageErr проверяет, что ошибка записи в журнал видна через StorageErr.
+func TestStorageErr(t *testing.T) {
+	l, err := wal.Open(wal.Options{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("wal open: %v", err)
+	}
+	q, err := NewDurableQueue(4, l)
+	if err != nil {
+		t.Fatalf("new durable queue: %v", err)
+	}
+	if err := q.Enqueue(Job{ID: "a"}); err != nil || q.StorageErr() != nil {
+		t.Fatalf("expected healthy storage, got %v %v", err, q.StorageErr())
+	}
+	_ = l.Close()
+	if err := q.Enqueue(Job{ID: "b"}); err == nil {
+		t.Fatal("expected enqueue to fail with closed journal")
+	}
+	if !errors.Is(q.StorageErr(), wal.ErrClosed) {
+		t.Fatalf("expected journal error, got %v", q.StorageErr())
+	}
+}
+
+// TestJournalCompactsAtRuntime проверяет, что журнал не растёт без ограничений
+// и после компактизации по-прежнему восстанавливает незавершённые задания.
+func TestJournalCompactsAtRuntime(t *testing.T) {
+	dir := t.TempDir()
+	l, err := wal.Open(wal.Options{Dir: dir, Sync: wal.SyncNever})
+	if err != nil {
+		t.Fatalf("wal open: %v", err)
+	}
+	q, err := NewDurableQueue(4, l)
+	if err != nil {
+		t.Fatalf("new durable queue: %v", err)
+	}
+	for _, id := range []string{"busy", "waiting"} {
+		if err := q.Enqueue(Job{ID: id, Payload: "p-" + id}); err != nil {
+			t.Fatalf("enqueue %s: %v", id, err)
+		}
+	}
+	job, _ := q.Next()
+	q.UpdatesStateRunning(job.ID)
+	for i := 0; i < 3*compactMinGarbage; i++ {
+		q.UpdatesResult(job.ID, Result{Data: "partial"})
+	}
+	_ = l.Close()
+
+	l, err = wal.Open(wal.Options{Dir: dir, Sync: wal.SyncNever})
+	if err != nil {
+		t.Fatalf("wal reopen: %v", err)
+	}
+	defer l.Close()
+	records := 0
+	_ = l.Replay(func([]byte) error { records++; return nil })
+	if records > 2*compactMinGarbage {
+		t.Fatalf("expected journal compacted at runtime, got %d records", records)
+	}
+	q, err = NewDurableQueue(4, l)
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	defer q.Close()
+	for _, want := range []string{"busy", "waiting"} {
+		if job, ok := q.Next(); !ok || job.ID != want || job.Payload != "p-"+want {
+			t.Fatalf("expected %s requeued after compaction, got %+v ok=%v", want, job, ok)
+		}
+	}
+}
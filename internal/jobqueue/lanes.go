@@ -0,0 +1,187 @@
+package jobqueue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Priority — приоритет задания; задания разных приоритетов ждут в отдельных полосах.
+type Priority string
+
+const (
+	PriorityHigh   Priority = "high"
+	PriorityNormal Priority = "normal"
+	PriorityLow    Priority = "low"
+)
+
+// Priorities перечисляет приоритеты от высшего к низшему.
+var Priorities = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// DefaultWeights — веса полос по умолчанию: при заполненных полосах из каждых
+// десяти выдаваемых заданий шесть high, три normal и одно low.
+var DefaultWeights = map[Priority]int{PriorityHigh: 6, PriorityNormal: 3, PriorityLow: 1}
+
+// ParsePriority проверяет строковое значение приоритета; пустая строка означает normal.
+func ParsePriority(s string) (Priority, error) {
+	switch p := Priority(s); p {
+	case "":
+		return PriorityNormal, nil
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown priority %q", s)
+}
+
+// ParseWeights разбирает веса полос в формате "high=6,normal=3,low=1".
+// Неуказанные приоритеты получают веса по умолчанию.
+func ParseWeights(s string) (map[Priority]int, error) {
+	out := make(map[Priority]int, len(Priorities))
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid weight %q", part)
+		}
+		p, err := ParsePriority(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid weight %q", part)
+		}
+		out[p] = w
+	}
+	return out, nil
+}
+
+// lane — FIFO-полоса заданий одного приоритета.
+type lane struct {
+	priority Priority
+	weight   int
+	current  int // текущий вес для smooth weighted round-robin
+	items    []item
+}
+
+// newLanes создаёт полосы для всех приоритетов с заданными весами
+// (отсутствующие или неположительные веса берутся из DefaultWeights).
+func newLanes(weights map[Priority]int) []*lane {
+	out := make([]*lane, 0, len(Priorities))
+	for _, p := range Priorities {
+		w := weights[p]
+		if w <= 0 {
+			w = DefaultWeights[p]
+		}
+		out = append(out, &lane{priority: p, weight: w})
+	}
+	return out
+}
+
+// laneFor возвращает полосу приоритета p. Вызывается под q.mu.
+func (q *Queue) laneFor(p Priority) *lane {
+	for _, l := range q.lanes {
+		if l.priority == p {
+			return l
+		}
+	}
+	return q.lanes[1] // normal
+}
+
+// push добавляет задание в конец полосы его приоритета. Вызывается под q.mu.
+func (q *Queue) push(it item) {
+	l := q.laneFor(it.job.Priority)
+	l.items = append(l.items, it)
+	q.size++
+	q.cond.Signal()
+}
+
+// pop выбирает следующее задание взвешенным справедливым выбором (smooth weighted
+// round-robin, как в nginx) среди полос с заданиями, не задержанными skip (nil — без пропусков):
+// высокий приоритет выдаётся чаще, но низкий не голодает. В полосе берётся первое
+// незадержанное задание. Вызывается под q.mu, когда такое задание есть.
+func (q *Queue) pop(skip func(item) bool) item {
+	var best *lane
+	bestIdx, total := 0, 0
+	for _, l := range q.lanes {
+		i := l.nextIndex(skip)
+		if i < 0 {
+			continue
+		}
+		l.current += l.weight
+		total += l.weight
+		if best == nil || l.current > best.current {
+			best, bestIdx = l, i
+		}
+	}
+	best.current -= total
+	it := best.items[bestIdx]
+	if bestIdx == 0 {
+		best.items[0] = item{}
+		best.items = best.items[1:]
+	} else {
+		best.items = append(best.items[:bestIdx], best.items[bestIdx+1:]...)
+	}
+	if len(best.items) == 0 {
+		best.current = 0
+	}
+	q.size--
+	q.wakeScheduler()
+	return it
+}
+
+// TakePending извлекает из полос все ожидающие задания в порядке выдачи воркерам,
+// не меняя их состояния, включая задержанные паузой. Используется при остановке, чтобы сохранить невыполненные задания.
+func (q *Queue) TakePending() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Job, 0, q.size)
+	for q.size > 0 {
+		out = append(out, q.pop(nil).job)
+	}
+	return out
+}
+
+// remove удаляет ожидающее задание из полос и сообщает, было ли оно найдено.
+// Вызывается под q.mu.
+func (q *Queue) remove(id string) bool {
+	for _, l := range q.lanes {
+		for i, it := range l.items {
+			if it.job.ID == id {
+				l.items = append(l.items[:i], l.items[i+1:]...)
+				q.size--
+				q.wakeScheduler()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Depths возвращает число ожидающих заданий в каждой полосе.
+func (q *Queue) Depths() map[Priority]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make(map[Priority]int, len(q.lanes))
+	for _, l := range q.lanes {
+		out[l.priority] = len(l.items)
+	}
+	return out
+}
+
+// Len возвращает общее число ожидающих заданий.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Cap возвращает ёмкость очереди.
+func (q *Queue) Cap() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.capacity
+}
@@ -0,0 +1,120 @@
+package jobqueue
+
+import "slices"
+
+// PauseState — текущие паузы выдачи заданий воркерам.
+type PauseState struct {
+	All   bool       // выдача приостановлена целиком
+	Lanes []Priority // приостановленные полосы приоритетов, от высшего к низшему
+	Types []string   // приостановленные виды заданий, по алфавиту
+}
+
+// Pause приостанавливает выдачу всех заданий: постановка продолжается, а Next ждёт Resume.
+// Паузы полос и видов заданий сохраняются и действуют после Resume.
+func (q *Queue) Pause() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused = true
+}
+
+// Resume снимает общую паузу, установленную Pause.
+func (q *Queue) Resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused = false
+	q.cond.Broadcast()
+}
+
+// PauseLane приостанавливает выдачу заданий приоритета p.
+func (q *Queue) PauseLane(p Priority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pausedLanes[p] = true
+}
+
+// ResumeLane возобновляет выдачу заданий приоритета p.
+func (q *Queue) ResumeLane(p Priority) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pausedLanes, p)
+	q.cond.Broadcast()
+}
+
+// PauseType приостанавливает выдачу заданий вида typ (Job.Type).
+func (q *Queue) PauseType(typ string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pausedTypes[typ] = true
+}
+
+// ResumeType возобновляет выдачу заданий вида typ.
+func (q *Queue) ResumeType(typ string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pausedTypes, typ)
+	q.cond.Broadcast()
+}
+
+// Paused возвращает текущие паузы.
+func (q *Queue) Paused() PauseState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	st := PauseState{All: q.paused, Lanes: []Priority{}, Types: []string{}}
+	for _, p := range Priorities {
+		if q.pausedLanes[p] {
+			st.Lanes = append(st.Lanes, p)
+		}
+	}
+	for typ := range q.pausedTypes {
+		st.Types = append(st.Types, typ)
+	}
+	slices.Sort(st.Types)
+	return st
+}
+
+// held сообщает, задержано ли задание паузой. Вызывается под q.mu.
+func (q *Queue) held(it item) bool {
+	return q.paused || q.pausedLanes[it.job.Priority] || q.pausedTypes[it.job.Type]
+}
+
+// nextIndex возвращает индекс первого задания полосы, не задержанного skip, или -1.
+// Вызывается под q.mu.
+func (l *lane) nextIndex(skip func(item) bool) int {
+	if skip == nil {
+		if len(l.items) == 0 {
+			return -1
+		}
+		return 0
+	}
+	for i, it := range l.items {
+		if !skip(it) {
+			return i
+		}
+	}
+	return -1
+}
+
+// available сообщает, есть ли задание, которое можно выдать воркеру. Вызывается под q.mu.
+func (q *Queue) available() bool {
+	for _, l := range q.lanes {
+		if l.nextIndex(q.held) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Available возвращает число ожидающих заданий, не задержанных паузой.
+func (q *Queue) Available() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, l := range q.lanes {
+		for _, it := range l.items {
+			if !q.held(it) {
+				n++
+			}
+		}
+	}
+	return n
+}
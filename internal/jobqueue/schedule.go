@@ -0,0 +1,145 @@
+package jobqueue
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// timerHeap — min-куча отложенных заданий по времени запуска (при равенстве — по порядку постановки).
+type timerHeap []timerItem
+
+// timerItem — отложенное задание и его порядковый номер постановки.
+type timerItem struct {
+	it  item
+	seq uint64
+}
+
+func (h timerHeap) Len() int { return len(h) }
+func (h timerHeap) Less(i, k int) bool {
+	if !h[i].it.job.RunAt.Equal(h[k].it.job.RunAt) {
+		return h[i].it.job.RunAt.Before(h[k].it.job.RunAt)
+	}
+	return h[i].seq < h[k].seq
+}
+func (h timerHeap) Swap(i, k int) { h[i], h[k] = h[k], h[i] }
+func (h *timerHeap) Push(x any)   { *h = append(*h, x.(timerItem)) }
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = timerItem{}
+	*h = old[:n-1]
+	return x
+}
+
+// schedule помещает задание в кучу отложенных и при необходимости запускает
+// планировщик. Вызывается под q.mu.
+func (q *Queue) schedule(it item, seq uint64) {
+	heap.Push(&q.timers, timerItem{it: it, seq: seq})
+	if !q.schedRunning {
+		q.schedRunning = true
+		q.schedDone = make(chan struct{})
+		go q.runScheduler()
+	}
+	q.wakeScheduler()
+}
+
+// wakeScheduler будит планировщик: изменилась вершина кучи или освободилось место.
+// Вызывается под q.mu.
+func (q *Queue) wakeScheduler() {
+	if !q.schedRunning {
+		return
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// unschedule удаляет отложенное задание из кучи и сообщает, было ли оно найдено.
+// Вызывается под q.mu.
+func (q *Queue) unschedule(id string) bool {
+	for i, ti := range q.timers {
+		if ti.it.job.ID == id {
+			heap.Remove(&q.timers, i)
+			q.wakeScheduler()
+			return true
+		}
+	}
+	return false
+}
+
+// promoteDue переносит наступившие задания из кучи в полосы, пока есть место,
+// и возвращает время ожидания до следующего действия: 0 — ждать освобождения
+// места или новых заданий без таймера. Вызывается под q.mu.
+func (q *Queue) promoteDue(now time.Time) (wait time.Duration, armed bool) {
+	for len(q.timers) > 0 {
+		next := q.timers[0]
+		if next.it.job.RunAt.After(now) {
+			return next.it.job.RunAt.Sub(now), true
+		}
+		if q.size >= q.capacity {
+			return 0, false
+		}
+		heap.Pop(&q.timers)
+		q.transition(next.it.job.ID, func(ji *JobInfo) { ji.State = StateQueued })
+		q.push(next.it)
+	}
+	return 0, false
+}
+
+// runScheduler — цикл планировщика: спит до ближайшего срока и переносит
+// наступившие задания в полосы, соблюдая ёмкость очереди. Завершается при Close.
+func (q *Queue) runScheduler() {
+	defer close(q.schedDone)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		wait, armed := q.promoteDue(time.Now())
+		q.mu.Unlock()
+
+		var fire <-chan time.Time
+		if armed {
+			timer.Reset(wait)
+			fire = timer.C
+		}
+		select {
+		case <-fire:
+		case <-q.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// Unscheduled возвращает отложенные задания, так и не перенесённые в очередь.
+// После Close позволяет сообщить о них вместо молчаливой потери.
+func (q *Queue) Unscheduled() []Job {
+	q.mu.Lock()
+	done := q.schedDone
+	q.mu.Unlock()
+	if done != nil && q.isClosed() {
+		<-done
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	sorted := append(timerHeap(nil), q.timers...)
+	sort.Sort(sorted)
+	out := make([]Job, 0, len(sorted))
+	for _, ti := range sorted {
+		out = append(out, ti.it.job)
+	}
+	return out
+}
+
+// isClosed сообщает, закрыта ли очередь.
+func (q *Queue) isClosed() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closed
+}
@@ -0,0 +1,73 @@
+package jobqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestScheduledJobBecomesReady проверяет, что отложенное задание недоступно до срока
+// и переходит из scheduled в queued по его наступлении.
+func TestScheduledJobBecomesReady(t *testing.T) {
+	q := NewQueue(4)
+	defer q.Close()
+	_ = q.Enqueue(Job{ID: "later", RunAt: time.Now().Add(60 * time.Millisecond)})
+	_ = q.Enqueue(Job{ID: "now"})
+	if st := q.StatesSnapshot()["later"]; st != StateScheduled {
+		t.Fatalf("expected scheduled, got %v", st)
+	}
+	if j, _ := q.Next(); j.ID != "now" {
+		t.Fatalf("expected immediate job first, got %s", j.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	j, ok := q.NextContext(ctx)
+	if !ok || j.ID != "later" {
+		t.Fatalf("expected delayed job, got %+v ok=%v", j, ok)
+	}
+	if time.Since(start) < 30*time.Millisecond {
+		t.Fatalf("delayed job released too early")
+	}
+	if st := q.StatesSnapshot()["later"]; st != StateQueued {
+		t.Fatalf("expected queued after due, got %v", st)
+	}
+}
+
+// TestScheduledRespectsCapacity проверяет, что наступившее задание ждёт свободного места.
+func TestScheduledRespectsCapacity(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Close()
+	_ = q.Enqueue(Job{ID: "due", RunAt: time.Now().Add(10 * time.Millisecond)})
+	_ = q.Enqueue(Job{ID: "blocker"})
+	time.Sleep(40 * time.Millisecond)
+	if st := q.StatesSnapshot()["due"]; st != StateScheduled || q.Len() != 1 {
+		t.Fatalf("expected due job held while full, state=%v len=%d", st, q.Len())
+	}
+	if j, _ := q.Next(); j.ID != "blocker" {
+		t.Fatalf("expected blocker, got %s", j.ID)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if j, ok := q.NextContext(ctx); !ok || j.ID != "due" {
+		t.Fatalf("expected due job after slot freed, got %+v ok=%v", j, ok)
+	}
+}
+
+// TestScheduledCancelAndUnscheduled проверяет отмену отложенного задания
+// и отчёт о не наступивших заданиях после Close.
+func TestScheduledCancelAndUnscheduled(t *testing.T) {
+	q := NewQueue(4)
+	_ = q.Enqueue(Job{ID: "c", RunAt: time.Now().Add(time.Hour)})
+	_ = q.Enqueue(Job{ID: "b", RunAt: time.Now().Add(2 * time.Hour)})
+	_ = q.Enqueue(Job{ID: "a", RunAt: time.Now().Add(time.Hour / 2)})
+	if prev, err := q.Cancel("c"); err != nil || prev != StateScheduled {
+		t.Fatalf("cancel scheduled: prev=%v err=%v", prev, err)
+	}
+	q.Close()
+	left := q.Unscheduled()
+	if len(left) != 2 || left[0].ID != "a" || left[1].ID != "b" {
+		t.Fatalf("unexpected unscheduled jobs: %+v", left)
+	}
+}
@@ -0,0 +1,192 @@
+package jobqueue
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound возвращается хранилищем, если задания с указанным идентификатором нет.
+var ErrNotFound = errors.New("job not found")
+
+// Store — хранилище сведений о заданиях. Реализации должны быть потокобезопасными.
+type Store interface {
+	// Put создаёт или полностью заменяет запись о задании.
+	Put(ji JobInfo) error
+	// Get возвращает запись о задании или ErrNotFound.
+	Get(id string) (JobInfo, error)
+	// Transition атомарно применяет fn к записи и возвращает её новое значение.
+	// Для отсутствующего задания возвращает ErrNotFound, fn не вызывается.
+	Transition(id string, fn func(ji *JobInfo)) (JobInfo, error)
+	// List возвращает страницу записей в порядке Seq и курсор следующей страницы.
+	List(f ListFilter) ([]JobInfo, uint64, error)
+	// Delete удаляет запись; удаление отсутствующей записи не является ошибкой.
+	Delete(id string) error
+	// Close освобождает ресурсы хранилища.
+	Close() error
+}
+
+// errReporter — необязательный интерфейс хранилища с фоновой ошибкой, не относящейся
+// к отдельной операции (например, компактизации FileStore). Учитывается Queue.StorageErr.
+type errReporter interface {
+	Err() error
+}
+
+// ListFilter задаёт условия выборки заданий для List.
+type ListFilter struct {
+	State  State     // пустое значение — любое состояние
+	Prefix string    // префикс идентификатора
+	From   time.Time // нижняя граница времени постановки (включительно), нулевое — без ограничения
+	To     time.Time // верхняя граница времени постановки (исключительно), нулевое — без ограничения
+	After  uint64    // курсор: Seq последнего задания предыдущей страницы
+	Limit  int       // максимальный размер страницы, <= 0 — без ограничения
+}
+
+// indexEntry — элемент индекса заданий в порядке постановки.
+type indexEntry struct {
+	seq uint64
+	id  string
+}
+
+// MemoryStore — хранилище в памяти процесса: карта по идентификатору
+// и индекс в порядке Seq для постраничного листинга.
+type MemoryStore struct {
+	mu    sync.Mutex
+	byID  map[string]*JobInfo
+	order []indexEntry // отсортирован по seq; записи заменённых и удалённых заданий устаревают
+	stale int          // число устаревших записей в order
+}
+
+// NewMemoryStore создаёт пустое хранилище в памяти.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{byID: make(map[string]*JobInfo)}
+}
+
+// Put создаёт или заменяет запись о задании.
+func (s *MemoryStore) Put(ji JobInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(ji)
+	return nil
+}
+
+// put сохраняет запись и поддерживает индекс. Вызывается под s.mu.
+func (s *MemoryStore) put(ji JobInfo) {
+	old, ok := s.byID[ji.ID]
+	if ok && old.Seq == ji.Seq {
+		*old = ji
+		return
+	}
+	if ok {
+		s.stale++
+	}
+	s.byID[ji.ID] = &ji
+	e := indexEntry{seq: ji.Seq, id: ji.ID}
+	if n := len(s.order); n == 0 || s.order[n-1].seq <= ji.Seq {
+		s.order = append(s.order, e)
+	} else {
+		i := sort.Search(n, func(i int) bool { return s.order[i].seq > ji.Seq })
+		s.order = append(s.order, indexEntry{})
+		copy(s.order[i+1:], s.order[i:])
+		s.order[i] = e
+	}
+	s.compact()
+}
+
+// Get возвращает копию записи о задании.
+func (s *MemoryStore) Get(id string) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ji, ok := s.byID[id]
+	if !ok {
+		return JobInfo{}, ErrNotFound
+	}
+	return *ji, nil
+}
+
+// Transition атомарно изменяет запись о задании.
+func (s *MemoryStore) Transition(id string, fn func(ji *JobInfo)) (JobInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ji, ok := s.byID[id]
+	if !ok {
+		return JobInfo{}, ErrNotFound
+	}
+	next := *ji
+	fn(&next)
+	next.ID, next.Seq = ji.ID, ji.Seq
+	*ji = next
+	return next, nil
+}
+
+// List возвращает страницу заданий, упорядоченных по времени постановки,
+// и курсор следующей страницы (0, если страниц больше нет).
+func (s *MemoryStore) List(f ListFilter) ([]JobInfo, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	start := sort.Search(len(s.order), func(i int) bool { return s.order[i].seq > f.After })
+	var out []JobInfo
+	for _, e := range s.order[start:] {
+		ji, ok := s.byID[e.id]
+		if !ok || ji.Seq != e.seq {
+			continue
+		}
+		if !f.To.IsZero() && !ji.EnqueuedAt.Before(f.To) {
+			break
+		}
+		if !f.From.IsZero() && ji.EnqueuedAt.Before(f.From) {
+			continue
+		}
+		if f.State != "" && ji.State != f.State {
+			continue
+		}
+		if !strings.HasPrefix(ji.ID, f.Prefix) {
+			continue
+		}
+		if f.Limit > 0 && len(out) == f.Limit {
+			return out, out[len(out)-1].Seq, nil
+		}
+		out = append(out, *ji)
+	}
+	return out, 0, nil
+}
+
+// Delete удаляет запись о задании.
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byID[id]; ok {
+		delete(s.byID, id)
+		s.stale++
+		s.compact()
+	}
+	return nil
+}
+
+// Len возвращает число записей в хранилище.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byID)
+}
+
+// Close ничего не делает: хранилищу в памяти нечего освобождать.
+func (s *MemoryStore) Close() error { return nil }
+
+// compact удаляет устаревшие записи индекса, когда их становится больше половины.
+// Вызывается под s.mu.
+func (s *MemoryStore) compact() {
+	if s.stale == 0 || s.stale*2 < len(s.order) {
+		return
+	}
+	live := s.order[:0]
+	for _, e := range s.order {
+		if ji, ok := s.byID[e.id]; ok && ji.Seq == e.seq {
+			live = append(live, e)
+		}
+	}
+	s.order = live
+	s.stale = 0
+}
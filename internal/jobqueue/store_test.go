@@ -0,0 +1,214 @@
+package jobqueue
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"kaspContainers/internal/wal"
+)
+
+// testStoreConformance — общий набор проверок, который обязана проходить каждая реализация Store.
+func testStoreConformance(t *testing.T, newStore func(t *testing.T) Store) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	fill := func(t *testing.T, s Store) {
+		t.Helper()
+		for i, id := range []string{"a1", "b1", "a2", "a3", "b2"} {
+			ji := JobInfo{ID: id, Seq: uint64(i + 1), State: StateQueued, EnqueuedAt: base.Add(time.Duration(i) * time.Minute)}
+			if err := s.Put(ji); err != nil {
+				t.Fatalf("put %s: %v", id, err)
+			}
+		}
+	}
+
+	t.Run("PutGet", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		if _, err := s.Get("missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		if err := s.Put(JobInfo{ID: "x", Seq: 1, State: StateQueued, PayloadSize: 3}); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		ji, err := s.Get("x")
+		if err != nil || ji.State != StateQueued || ji.PayloadSize != 3 {
+			t.Fatalf("unexpected get: %+v err=%v", ji, err)
+		}
+	})
+
+	t.Run("Transition", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		if _, err := s.Transition("missing", func(*JobInfo) { t.Fatalf("fn called for missing job") }); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+		_ = s.Put(JobInfo{ID: "x", Seq: 1, State: StateQueued})
+		ji, err := s.Transition("x", func(ji *JobInfo) {
+			ji.State = StateRunning
+			ji.Attempts++
+			ji.ID, ji.Seq = "hijack", 42
+		})
+		if err != nil || ji.State != StateRunning || ji.Attempts != 1 || ji.ID != "x" || ji.Seq != 1 {
+			t.Fatalf("unexpected transition result: %+v err=%v", ji, err)
+		}
+		if got, _ := s.Get("x"); !reflect.DeepEqual(got, ji) {
+			t.Fatalf("transition not persisted: %+v", got)
+		}
+	})
+
+	t.Run("ListOrderFiltersAndCursor", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		fill(t, s)
+		_, _ = s.Transition("a2", func(ji *JobInfo) { ji.State = StateFailed })
+
+		page, next, err := s.List(ListFilter{Prefix: "a", Limit: 2})
+		if err != nil || len(page) != 2 || page[0].ID != "a1" || page[1].ID != "a2" || next == 0 {
+			t.Fatalf("unexpected first page: %+v next=%d err=%v", page, next, err)
+		}
+		page, next, _ = s.List(ListFilter{Prefix: "a", Limit: 2, After: next})
+		if len(page) != 1 || page[0].ID != "a3" || next != 0 {
+			t.Fatalf("unexpected second page: %+v next=%d", page, next)
+		}
+		page, _, _ = s.List(ListFilter{State: StateFailed})
+		if len(page) != 1 || page[0].ID != "a2" {
+			t.Fatalf("unexpected state filter: %+v", page)
+		}
+		page, _, _ = s.List(ListFilter{From: base.Add(time.Minute), To: base.Add(3 * time.Minute)})
+		if len(page) != 2 || page[0].ID != "b1" || page[1].ID != "a2" {
+			t.Fatalf("unexpected time range: %+v", page)
+		}
+	})
+
+	t.Run("ReplaceMovesToEnd", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		fill(t, s)
+		_ = s.Put(JobInfo{ID: "a1", Seq: 6, State: StateQueued, EnqueuedAt: base.Add(time.Hour)})
+		page, _, _ := s.List(ListFilter{})
+		if len(page) != 5 || page[4].ID != "a1" || page[0].ID != "b1" {
+			t.Fatalf("unexpected order after replace: %+v", page)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		s := newStore(t)
+		defer s.Close()
+		fill(t, s)
+		if err := s.Delete("b1"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if err := s.Delete("missing"); err != nil {
+			t.Fatalf("delete missing: %v", err)
+		}
+		if _, err := s.Get("b1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound after delete, got %v", err)
+		}
+		if page, _, _ := s.List(ListFilter{}); len(page) != 4 {
+			t.Fatalf("expected 4 jobs after delete, got %d", len(page))
+		}
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStoreConformance(t, func(*testing.T) Store { return NewMemoryStore() })
+}
+
+func TestFileStore(t *testing.T) {
+	testStoreConformance(t, func(t *testing.T) Store {
+		s, err := OpenFileStore(filepath.Join(t.TempDir(), "jobs.db"), wal.SyncNever)
+		if err != nil {
+			t.Fatalf("open file store: %v", err)
+		}
+		return s
+	})
+}
+
+// TestFileStoreReopenAndCompact проверяет, что записи переживают переоткрытие,
+// а файл компактизируется при накоплении устаревших записей.
+func TestFileStoreReopenAndCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	s, err := OpenFileStore(path, wal.SyncNever)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = s.Put(JobInfo{ID: "keep", Seq: 1, State: StateQueued})
+	_ = s.Put(JobInfo{ID: "gone", Seq: 2, State: StateQueued})
+	for i := 0; i < compactMinGarbage; i++ {
+		_, _ = s.Transition("keep", func(ji *JobInfo) { ji.Attempts++ })
+	}
+	if s.garbage >= compactMinGarbage {
+		t.Fatalf("expected compaction, garbage=%d", s.garbage)
+	}
+	_ = s.Delete("gone")
+	_ = s.Close()
+
+	s, err = OpenFileStore(path, wal.SyncNever)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	ji, err := s.Get("keep")
+	if err != nil || ji.Attempts != compactMinGarbage {
+		t.Fatalf("unexpected record after reopen: %+v err=%v", ji, err)
+	}
+	if _, err := s.Get("gone"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected deleted record to stay deleted, got %v", err)
+	}
+}
+
+// TestFileStoreCompactionErrorKeepsChange проверяет, что неудачная компактизация не делает
+// уже записанное изменение неуспешным, а её ошибка видна через Err и Queue.StorageErr.
+func TestFileStoreCompactionErrorKeepsChange(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "store")
+	s, err := OpenFileStore(filepath.Join(dir, "jobs.db"), wal.SyncNever)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	q, err := Open(Options{BufferSize: 1, Store: s})
+	if err != nil {
+		t.Fatalf("open queue: %v", err)
+	}
+	_ = q.Enqueue(Job{ID: "x"})
+	_ = os.RemoveAll(dir) // файл остаётся открытым, но переписать его снимком уже нельзя
+	for i := 0; i < compactMinGarbage; i++ {
+		ji, err := s.Transition("x", func(ji *JobInfo) { ji.Attempts++ })
+		if err != nil || ji.Attempts != i+1 {
+			t.Fatalf("transition %d: %+v err=%v", i, ji, err)
+		}
+	}
+	if s.Err() == nil {
+		t.Fatal("expected compaction error")
+	}
+	q.UpdatesStateDone("x")
+	if ji, _ := q.Get("x"); ji.State != StateDone {
+		t.Fatalf("expected transition applied despite compaction error, got %s", ji.State)
+	}
+	if q.StorageErr() == nil {
+		t.Fatal("expected compaction error reported by StorageErr")
+	}
+}
+
+// TestOpenMarksLostJobsFailed проверяет, что без журнала незавершённые задания
+// из постоянного хранилища помечаются failed при старте.
+func TestOpenMarksLostJobsFailed(t *testing.T) {
+	st := NewMemoryStore()
+	_ = st.Put(JobInfo{ID: "q", Seq: 1, State: StateQueued})
+	_ = st.Put(JobInfo{ID: "d", Seq: 2, State: StateDone})
+	q, err := Open(Options{BufferSize: 1, Store: st})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer q.Close()
+	if ji, _ := q.Get("q"); ji.State != StateFailed || ji.LastError == "" {
+		t.Fatalf("expected q failed, got %+v", ji)
+	}
+	_ = q.Enqueue(Job{ID: "n"})
+	if ji, _ := q.Get("n"); ji.Seq != 3 {
+		t.Fatalf("expected seq to continue, got %d", ji.Seq)
+	}
+}
@@ -0,0 +1,58 @@
+package jobqueue
+
+import (
+	"context"
+	"slices"
+)
+
+// Wait блокируется, пока задание id не перейдёт в завершённое состояние (done, failed или cancelled),
+// и возвращает его сведения. Если ctx отменён раньше, возвращает текущие сведения о задании и ctx.Err().
+// Для неизвестного задания сразу возвращает ErrNotFound.
+func (q *Queue) Wait(ctx context.Context, id string) (JobInfo, error) {
+	for {
+		q.mu.Lock()
+		ji, err := q.store.Get(id)
+		if err != nil || ji.State.Terminal() {
+			q.mu.Unlock()
+			return ji, err
+		}
+		ch := make(chan struct{})
+		q.waiters[id] = append(q.waiters[id], ch)
+		q.mu.Unlock()
+
+		select {
+		case <-ch:
+			// Состояние перечитывается: задание могло быть сразу перезапущено (rerun, redrive).
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.removeWaiter(id, ch)
+			ji, err = q.store.Get(id)
+			q.mu.Unlock()
+			if err != nil {
+				return ji, err
+			}
+			if ji.State.Terminal() {
+				return ji, nil
+			}
+			return ji, ctx.Err()
+		}
+	}
+}
+
+// wakeWaiters будит всех ожидающих завершения задания id. Вызывается под q.mu.
+func (q *Queue) wakeWaiters(id string) {
+	for _, ch := range q.waiters[id] {
+		close(ch)
+	}
+	delete(q.waiters, id)
+}
+
+// removeWaiter снимает ожидание ch, прерванное отменой контекста. Вызывается под q.mu.
+func (q *Queue) removeWaiter(id string, ch chan struct{}) {
+	ws := slices.DeleteFunc(q.waiters[id], func(c chan struct{}) bool { return c == ch })
+	if len(ws) == 0 {
+		delete(q.waiters, id)
+		return
+	}
+	q.waiters[id] = ws
+}
@@ -0,0 +1,41 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, "json", "warn")
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	l.Info("hidden")
+	l.Warn("shown", "job_id", "j1")
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("expected single JSON record, got %q: %v", buf.String(), err)
+	}
+	if rec["msg"] != "shown" || rec["job_id"] != "j1" {
+		t.Fatalf("unexpected record: %v", rec)
+	}
+
+	buf.Reset()
+	if l, err = New(&buf, "text", "debug"); err != nil {
+		t.Fatalf("new text: %v", err)
+	}
+	l.Debug("dbg", "attempt", 2)
+	if !strings.Contains(buf.String(), "msg=dbg attempt=2") {
+		t.Fatalf("unexpected text output %q", buf.String())
+	}
+
+	if _, err := New(&buf, "xml", "info"); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+	if _, err := New(&buf, "text", "loud"); err == nil {
+		t.Fatalf("expected error for unknown level")
+	}
+}
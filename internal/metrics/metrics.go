@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets — границы гистограмм по умолчанию, в секундах.
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry хранит метрики и выводит их в формате Prometheus.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+	hooks   []func()
+}
+
+// metric — метрика с общими для всех типов именем, описанием и метками.
+type metric interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+// NewRegistry создаёт пустой реестр.
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// OnScrape регистрирует функцию, вызываемую перед каждым выводом метрик;
+// используется для обновления gauge, значения которых удобнее снимать в момент запроса.
+func (r *Registry) OnScrape(fn func()) {
+	r.mu.Lock()
+	r.hooks = append(r.hooks, fn)
+	r.mu.Unlock()
+}
+
+// register добавляет метрику; повторная регистрация имени — ошибка программиста.
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := m.desc().name
+	if r.names[name] {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// Write выводит все метрики в текстовом формате Prometheus (version 0.0.4).
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	hooks := append([]func(){}, r.hooks...)
+	metrics := append([]metric{}, r.metrics...)
+	r.mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// desc — имя, описание, тип и имена меток метрики, а также её серии по значениям меток.
+type desc struct {
+	name, help, typ string
+	labels          []string
+
+	mu     sync.Mutex
+	series map[string][]string // ключ серии → значения меток
+}
+
+func newDesc(name, help, typ string, labels []string) *desc {
+	return &desc{name: name, help: help, typ: typ, labels: labels, series: make(map[string][]string)}
+}
+
+// key проверяет число значений меток и возвращает ключ серии. Вызывается под d.mu.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	k := strings.Join(values, "\xff")
+	if _, ok := d.series[k]; !ok {
+		d.series[k] = append([]string(nil), values...)
+	}
+	return k
+}
+
+// sortedKeys возвращает ключи серий в детерминированном порядке. Вызывается под d.mu.
+func (d *desc) sortedKeys() []string {
+	keys := make([]string, 0, len(d.series))
+	for k := range d.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// header выводит строки HELP и TYPE.
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, d.typ)
+}
+
+// labelPairs форматирует метки серии с необязательной дополнительной меткой (le у гистограмм).
+func (d *desc) labelPairs(values []string, extraName, extraValue string) string {
+	if len(values) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+	}
+	if extraName != "" {
+		if len(values) > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Counter — монотонно растущий счётчик с необязательными метками.
+type Counter struct {
+	d      *desc
+	values map[string]float64
+}
+
+// NewCounter регистрирует счётчик с заданными именами меток.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{d: newDesc(name, help, "counter", labels), values: make(map[string]float64)}
+	if len(labels) == 0 {
+		c.d.key(nil) // серия без меток выводится и до первого изменения
+	}
+	r.register(c)
+	return c
+}
+
+// Inc увеличивает серию с заданными значениями меток на единицу.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add увеличивает серию на v; отрицательные значения недопустимы.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.d.mu.Lock()
+	c.values[c.d.key(labelValues)] += v
+	c.d.mu.Unlock()
+}
+
+// Value возвращает текущее значение серии.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	return c.values[strings.Join(labelValues, "\xff")]
+}
+
+func (c *Counter) desc() *desc { return c.d }
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.header(w)
+	for _, k := range c.d.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.d.name, c.d.labelPairs(c.d.series[k], "", ""), formatFloat(c.values[k]))
+	}
+}
+
+// Gauge — значение, которое может расти и уменьшаться.
+type Gauge struct {
+	d      *desc
+	values map[string]float64
+}
+
+// NewGauge регистрирует gauge с заданными именами меток.
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{d: newDesc(name, help, "gauge", labels), values: make(map[string]float64)}
+	if len(labels) == 0 {
+		g.d.key(nil)
+	}
+	r.register(g)
+	return g
+}
+
+// Set устанавливает значение серии.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.d.mu.Lock()
+	g.values[g.d.key(labelValues)] = v
+	g.d.mu.Unlock()
+}
+
+// Add изменяет значение серии на v.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.d.mu.Lock()
+	g.values[g.d.key(labelValues)] += v
+	g.d.mu.Unlock()
+}
+
+// Value возвращает текущее значение серии.
+func (g *Gauge) Value(labelValues ...string) float64 {
+	g.d.mu.Lock()
+	defer g.d.mu.Unlock()
+	return g.values[strings.Join(labelValues, "\xff")]
+}
+
+func (g *Gauge) desc() *desc { return g.d }
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.d.mu.Lock()
+	defer g.d.mu.Unlock()
+	g.d.header(w)
+	for _, k := range g.d.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", g.d.name, g.d.labelPairs(g.d.series[k], "", ""), formatFloat(g.values[k]))
+	}
+}
+
+// Histogram считает наблюдения по корзинам с верхними границами buckets.
+type Histogram struct {
+	d       *desc
+	buckets []float64
+	values  map[string]*histValue
+}
+
+// histValue — состояние одной серии гистограммы.
+type histValue struct {
+	counts []uint64 // по корзинам, не накопительно; последняя — +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram регистрирует гистограмму; buckets должны возрастать (nil — DefBuckets).
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: histogram buckets must be sorted: " + name)
+	}
+	h := &Histogram{d: newDesc(name, help, "histogram", labels), buckets: buckets, values: make(map[string]*histValue)}
+	r.register(h)
+	return h
+}
+
+// Observe учитывает наблюдение v в серии с заданными значениями меток.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	k := h.d.key(labelValues)
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histValue{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[k] = hv
+	}
+	hv.counts[sort.SearchFloat64s(h.buckets, v)]++
+	hv.sum += v
+	hv.count++
+}
+
+// Count возвращает число наблюдений серии.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	if hv, ok := h.values[strings.Join(labelValues, "\xff")]; ok {
+		return hv.count
+	}
+	return 0
+}
+
+func (h *Histogram) desc() *desc { return h.d }
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.d.mu.Lock()
+	defer h.d.mu.Unlock()
+	h.d.header(w)
+	for _, k := range h.d.sortedKeys() {
+		values, hv := h.d.series[k], h.values[k]
+		var cum uint64
+		for i, le := range h.buckets {
+			cum += hv.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.labelPairs(values, "le", formatFloat(le)), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.d.name, h.d.labelPairs(values, "le", "+Inf"), hv.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.d.name, h.d.labelPairs(values, "", ""), formatFloat(hv.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.d.name, h.d.labelPairs(values, "", ""), hv.count)
+	}
+}
+
+// formatFloat форматирует значение по правилам формата Prometheus.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestWriteExposition проверяет текстовый формат вывода всех типов метрик.
+func TestWriteExposition(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("jobs_total", "Jobs by result.", "result")
+	g := r.NewGauge("depth", "Queue depth.")
+	h := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "state")
+	r.OnScrape(func() { g.Set(3) })
+
+	c.Inc("ok")
+	c.Add(2, `b"ad`)
+	h.Observe(0.05, "done")
+	h.Observe(0.1, "done")
+	h.Observe(5, "done")
+
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `# HELP jobs_total Jobs by result.
+# TYPE jobs_total counter
+jobs_total{result="b\"ad"} 2
+jobs_total{result="ok"} 1
+# HELP depth Queue depth.
+# TYPE depth gauge
+depth 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{state="done",le="0.1"} 2
+latency_seconds_bucket{state="done",le="1"} 2
+latency_seconds_bucket{state="done",le="+Inf"} 3
+latency_seconds_sum{state="done"} 5.15
+latency_seconds_count{state="done"} 3
+`
+	if b.String() != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestLabelMismatchPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("x_total", "X.", "a")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on label count mismatch")
+		}
+	}()
+	c.Inc()
+}
@@ -1,27 +1,154 @@
 package processing
 
 import (
+	"context"
+	"errors"
 	"math/rand"
 	"time"
 )
 
+// Job — задание, передаваемое процессору.
+type Job struct {
+	ID      string
+	Payload string
+	Type    string // вид задания из запроса постановки; пусто — не задан
+	Attempt int    // номер попытки, начиная с 1
+}
+
+// Result — результат успешной обработки задания.
+type Result struct {
+	Data        string // пусто — задание не возвращает результата
+	ContentType string // пусто — application/octet-stream
+}
+
 // Processor инкапсулирует бизнес-логику обработки задания.
-// Process выполняет задание и возвращает успех и длительность выполнения.
+// Process выполняет задание и возвращает результат либо ошибку; подробности неуспеха
+// передаются через *Error. При отмене ctx обработка должна прерываться как можно быстрее.
 type Processor interface {
-	Process(jobID string, payload string) (ok bool, attemptDuration time.Duration)
+	Process(ctx context.Context, job Job) (Result, error)
+}
+
+// ProcessorFunc позволяет использовать функцию как Processor.
+type ProcessorFunc func(ctx context.Context, job Job) (Result, error)
+
+// Process вызывает f(ctx, job).
+func (f ProcessorFunc) Process(ctx context.Context, job Job) (Result, error) { return f(ctx, job) }
+
+// ErrFailed — ошибка неуспешной обработки без подробностей (например, от LegacyProcessor).
+var ErrFailed = errors.New("processing failed")
+
+// Class — класс ошибки обработки, определяющий, будет ли попытка повторена.
+type Class string
+
+const (
+	// ClassRetryable — временная ошибка: попытка повторяется после бэкоффа, пока не исчерпан max_retries.
+	ClassRetryable Class = "retryable"
+	// ClassPermanent — повтор бесполезен: задание сразу завершается неуспехом.
+	ClassPermanent Class = "permanent"
+	// ClassRateLimited — внешний сервис ограничил частоту: повтор выполняется через RetryAfter вместо бэкоффа.
+	ClassRateLimited Class = "rate_limited"
+)
+
+// Error — ошибка обработки с машиночитаемым кодом и классом, сохраняемыми в истории попыток.
+type Error struct {
+	Code       string // например, "invalid_payload"; пусто — "failed"
+	Message    string
+	Err        error         // исходная ошибка, необязательна
+	Class      Class         // пусто — ClassRetryable
+	RetryAfter time.Duration // задержка перед повтором для ClassRateLimited; 0 — по политике бэкоффа
+}
+
+// NewError создаёт повторяемую ошибку обработки с кодом code.
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Permanent создаёт ошибку, после которой задание завершается неуспехом без повторов.
+func Permanent(code, message string) *Error {
+	return &Error{Code: code, Message: message, Class: ClassPermanent}
+}
+
+// RateLimited создаёт ошибку ограничения частоты: повтор выполняется не раньше чем через retryAfter.
+func RateLimited(code, message string, retryAfter time.Duration) *Error {
+	return &Error{Code: code, Message: message, Class: ClassRateLimited, RetryAfter: retryAfter}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	}
+	return ErrFailed.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// ErrorCode возвращает код ошибки обработки: Code из *Error в цепочке err, "failed" для прочих ошибок
+// и пустую строку для nil.
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var pe *Error
+	if errors.As(err, &pe) && pe.Code != "" {
+		return pe.Code
+	}
+	return "failed"
+}
+
+// Classify возвращает класс ошибки обработки и предложенную задержку перед повтором.
+// Ошибки без *Error в цепочке считаются повторяемыми; для nil возвращается пустой класс.
+func Classify(err error) (Class, time.Duration) {
+	if err == nil {
+		return "", 0
+	}
+	var pe *Error
+	if !errors.As(err, &pe) || pe.Class == "" {
+		return ClassRetryable, 0
+	}
+	if pe.Class != ClassRateLimited || pe.RetryAfter < 0 {
+		return pe.Class, 0
+	}
+	return pe.Class, pe.RetryAfter
+}
+
+// LegacyProcessor — прежняя сигнатура процессора: успех и длительность попытки без результата.
+type LegacyProcessor interface {
+	Process(ctx context.Context, jobID string, payload string) (ok bool, attemptDuration time.Duration)
+}
+
+// Legacy адаптирует LegacyProcessor к Processor: неуспех превращается в ErrFailed,
+// результат всегда пустой.
+func Legacy(p LegacyProcessor) Processor {
+	return ProcessorFunc(func(ctx context.Context, job Job) (Result, error) {
+		if ok, _ := p.Process(ctx, job.ID, job.Payload); !ok {
+			return Result{}, ErrFailed
+		}
+		return Result{}, nil
+	})
 }
 
 // RandomProcessor — пример реализации: случайная длительность и вероятность ошибки.
+// Реализует LegacyProcessor; используется через Legacy.
 type RandomProcessor struct {
 	ErrorRate int // 0..100
 }
 
 // Process имитирует обработку задания: случайная длительность 100-500мс,
-// случайный успех/неуспех по ErrorRate.
-func (p RandomProcessor) Process(jobID string, payload string) (bool, time.Duration) {
+// случайный успех/неуспех по ErrorRate. Отмена ctx прерывает ожидание с неуспехом.
+func (p RandomProcessor) Process(ctx context.Context, jobID string, payload string) (bool, time.Duration) {
 	sleepMs := 100 + rand.Intn(401) // 100..500ms
 	d := time.Duration(sleepMs) * time.Millisecond
-	time.Sleep(d)
+	start := time.Now()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false, time.Since(start)
+	case <-t.C:
+	}
 	ok := rand.Intn(100) >= p.ErrorRate
 	return ok, d
 }
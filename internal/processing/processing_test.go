@@ -0,0 +1,67 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// legacyFunc реализует LegacyProcessor для тестов.
+type legacyFunc func() bool
+
+func (f legacyFunc) Process(ctx context.Context, jobID string, payload string) (bool, time.Duration) {
+	return f(), 0
+}
+
+func TestLegacyAdapter(t *testing.T) {
+	ok := Legacy(legacyFunc(func() bool { return true }))
+	if res, err := ok.Process(context.Background(), Job{ID: "a"}); err != nil || res.Data != "" {
+		t.Fatalf("expected empty success, got %+v %v", res, err)
+	}
+	fail := Legacy(legacyFunc(func() bool { return false }))
+	if _, err := fail.Process(context.Background(), Job{ID: "a"}); !errors.Is(err, ErrFailed) {
+		t.Fatalf("expected ErrFailed, got %v", err)
+	}
+	var _ LegacyProcessor = RandomProcessor{}
+}
+
+func TestErrorCode(t *testing.T) {
+	cause := errors.New("bad json")
+	wrapped := fmt.Errorf("decode: %w", &Error{Code: "invalid_payload", Err: cause})
+	for err, want := range map[error]string{
+		nil:                         "",
+		ErrFailed:                   "failed",
+		NewError("quota", "no way"): "quota",
+		&Error{Message: "x"}:        "failed",
+		wrapped:                     "invalid_payload",
+	} {
+		if got := ErrorCode(err); got != want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", err, got, want)
+		}
+	}
+	if !errors.Is(wrapped, cause) || (&Error{Err: cause}).Error() != "bad json" {
+		t.Fatal("expected wrapped cause to be reachable")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err   error
+		class Class
+		after time.Duration
+	}{
+		{nil, "", 0},
+		{ErrFailed, ClassRetryable, 0},
+		{NewError("quota", "x"), ClassRetryable, 0},
+		{Permanent("invalid_payload", "x"), ClassPermanent, 0},
+		{fmt.Errorf("call: %w", RateLimited("throttled", "x", 3*time.Second)), ClassRateLimited, 3 * time.Second},
+		{&Error{Class: ClassPermanent, RetryAfter: time.Second}, ClassPermanent, 0},
+	}
+	for _, c := range cases {
+		if class, after := Classify(c.err); class != c.class || after != c.after {
+			t.Errorf("Classify(%v) = %q, %v; want %q, %v", c.err, class, after, c.class, c.after)
+		}
+	}
+}
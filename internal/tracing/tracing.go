@@ -0,0 +1,380 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID и SpanID — идентификаторы трассы и спана в формате W3C Trace Context.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// FlagSampled — флаг traceparent, означающий, что трасса записывается.
+const FlagSampled = 0x01
+
+// SpanContext — переносимая часть спана: трасса, спан и флаги.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid сообщает, заданы ли идентификаторы трассы и спана.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Sampled сообщает, записывается ли трасса.
+func (sc SpanContext) Sampled() bool { return sc.Flags&FlagSampled != 0 }
+
+// Traceparent форматирует контекст как значение заголовка traceparent; для невалидного — пустая строка.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ErrInvalidTraceparent возвращается ParseTraceparent для некорректного заголовка.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent разбирает заголовок traceparent (W3C Trace Context, версия 00;
+// для будущих версий учитываются только первые четыре поля).
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || !isLowerHex(strings.Join(parts[:4], "")) {
+		return sc, ErrInvalidTraceparent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, ErrInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// isLowerHex проверяет, что s состоит из строчных шестнадцатеричных цифр.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext возвращает ctx с контекстом спана sc; дочерние спаны унаследуют его трассу.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext возвращает контекст текущего спана из ctx.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Kind — вид спана в терминах OpenTelemetry.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindProducer Kind = 4
+	KindConsumer Kind = 5
+)
+
+// SpanData — завершённый спан, передаваемый экспортёру.
+type SpanData struct {
+	Name       string         `json:"name"`
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_span_id,omitempty"`
+	Kind       Kind           `json:"kind"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Error      string         `json:"error,omitempty"` // пусто — спан завершился успешно
+}
+
+// Span — выполняющийся спан. Методы безопасны для nil.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext возвращает переносимый контекст спана.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttr задаёт атрибут спана; после End игнорируется.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]any)
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError отмечает спан как завершившийся ошибкой.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Error = err.Error()
+	}
+}
+
+// End завершает спан и передаёт его экспортёру, если трасса записывается.
+func (s *Span) End() { s.EndAt(time.Now()) }
+
+// EndAt завершает спан в момент t. Повторные вызовы игнорируются.
+func (s *Span) EndAt(t time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = t
+	data := s.data
+	s.mu.Unlock()
+	if s.sc.Sampled() {
+		s.tracer.enqueue(data)
+	}
+}
+
+// Exporter отправляет завершённые спаны во внешнее хранилище.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Options задаёт параметры Tracer.
+type Options struct {
+	Exporter      Exporter      // nil — спаны не экспортируются, но контекст трассы распространяется
+	BatchSize     int           // максимальный размер пачки, по умолчанию 256
+	FlushInterval time.Duration // период отправки неполной пачки, по умолчанию 1s
+	QueueSize     int           // буфер завершённых спанов, по умолчанию 4096; при переполнении спаны отбрасываются
+}
+
+// Tracer создаёт спаны и пачками передаёт завершённые спаны экспортёру в фоне.
+type Tracer struct {
+	opts  Options
+	spans chan SpanData
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// NewTracer создаёт трассировщик; при заданном экспортёре запускает фоновую отправку.
+func NewTracer(opts Options) *Tracer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 256
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 4096
+	}
+	t := &Tracer{opts: opts}
+	if opts.Exporter != nil {
+		t.spans = make(chan SpanData, opts.QueueSize)
+		t.flush = make(chan chan struct{})
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go t.run()
+	}
+	return t
+}
+
+// Start начинает спан с родителем из ctx и возвращает ctx с новым спаном.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	return t.StartAt(ctx, name, kind, time.Now())
+}
+
+// StartAt работает как Start, но с явным временем начала спана.
+func (t *Tracer) StartAt(ctx context.Context, name string, kind Kind, start time.Time) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	sc := SpanContext{Flags: FlagSampled}
+	if parent.IsValid() {
+		sc.TraceID, sc.Flags = parent.TraceID, parent.Flags
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+	s := &Span{tracer: t, sc: sc, data: SpanData{
+		Name:    name,
+		TraceID: sc.TraceID.String(),
+		SpanID:  sc.SpanID.String(),
+		Kind:    kind,
+		Start:   start,
+	}}
+	if parent.IsValid() {
+		s.data.ParentID = parent.SpanID.String()
+	}
+	return ContextWithSpanContext(ctx, sc), s
+}
+
+// enqueue ставит завершённый спан в очередь экспорта; при переполнении спан отбрасывается.
+func (t *Tracer) enqueue(data SpanData) {
+	if t.spans == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- data:
+	default:
+		t.dropped++
+	}
+}
+
+// Dropped возвращает число спанов, отброшенных из-за переполнения буфера.
+func (t *Tracer) Dropped() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.dropped
+}
+
+// run собирает спаны в пачки и передаёт их экспортёру.
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, t.opts.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.opts.Exporter.Export(context.Background(), batch); err != nil {
+			slog.Warn("trace export failed", "spans", len(batch), "err", err)
+		}
+		batch = make([]SpanData, 0, t.opts.BatchSize)
+	}
+	// drain досылает спаны, уже поставленные в буфер.
+	drain := func() {
+		for n := len(t.spans); n > 0; n-- {
+			batch = append(batch, <-t.spans)
+			if len(batch) >= t.opts.BatchSize {
+				export()
+			}
+		}
+		export()
+	}
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) >= t.opts.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			drain()
+			close(ack)
+		case <-t.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// Flush отправляет накопленные спаны и дожидается завершения отправки.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.spans == nil {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown отправляет оставшиеся спаны и закрывает экспортёр. Спаны, завершённые после вызова, теряются.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.spans == nil {
+		return nil
+	}
+	t.mu.Lock()
+	closed := t.closed
+	t.closed = true
+	t.mu.Unlock()
+	if closed {
+		return nil
+	}
+	close(t.stop)
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.opts.Exporter.Shutdown(ctx)
+}
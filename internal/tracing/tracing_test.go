@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// memExporter накапливает экспортированные спаны.
+type memExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func (e *memExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, spans...)
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *memExporter) Shutdown(context.Context) error { return nil }
+
+func TestParseTraceparent(t *testing.T) {
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(tp)
+	if err != nil || !sc.Sampled() || sc.Traceparent() != tp {
+		t.Fatalf("unexpected parse result %+v err=%v", sc, err)
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Fatalf("future version with extra fields must parse: %v", err)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(bad); !errors.Is(err, ErrInvalidTraceparent) {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+// TestTracerParentChild проверяет наследование трассы, экспорт и учёт флага sampled.
+func TestTracerParentChild(t *testing.T) {
+	exp := &memExporter{}
+	tr := NewTracer(Options{Exporter: exp})
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tr.Start(ContextWithSpanContext(context.Background(), parent), "root", KindServer)
+	_, child := tr.Start(ctx, "child", KindInternal)
+	child.SetAttr("n", 1)
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+
+	unsampled := parent
+	unsampled.Flags = 0
+	_, hidden := tr.Start(ContextWithSpanContext(context.Background(), unsampled), "hidden", KindInternal)
+	hidden.End()
+
+	if err := tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if len(exp.spans) != 2 {
+		t.Fatalf("expected 2 exported spans, got %+v", exp.spans)
+	}
+	c, r := exp.spans[0], exp.spans[1]
+	if r.TraceID != parent.TraceID.String() || r.ParentID != parent.SpanID.String() {
+		t.Fatalf("root not linked to remote parent: %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentID != r.SpanID || c.Error != "boom" || c.Attributes["n"] != 1 {
+		t.Fatalf("unexpected child span: %+v", c)
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	exp, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = exp.Export(context.Background(), []SpanData{{Name: "a"}, {Name: "b"}})
+	_ = exp.Shutdown(context.Background())
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	var names []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var s SpanData
+		if err := json.Unmarshal(sc.Bytes(), &s); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		names = append(names, s.Name)
+	}
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("unexpected spans %v", names)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer x" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	exp := &OTLPExporter{Endpoint: srv.URL, ServiceName: "svc", Headers: map[string]string{"Authorization": "Bearer x"}}
+	err := exp.Export(context.Background(), []SpanData{{
+		Name: "job.attempt", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		Kind: KindConsumer, Attributes: map[string]any{"attempt": 2}, Error: "failed",
+	}})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	rs := got.ResourceSpans
+	if len(rs) != 1 || rs[0].Resource.Attributes[0].Value["stringValue"] != "svc" {
+		t.Fatalf("unexpected resource: %+v", rs)
+	}
+	span := rs[0].ScopeSpans[0].Spans[0]
+	if span.Name != "job.attempt" || span.Status.Code != 2 || span.Attributes[0].Value["intValue"] != "2" {
+		t.Fatalf("unexpected span: %+v", span)
+	}
+
+	bad := &OTLPExporter{Endpoint: srv.URL}
+	if err := bad.Export(context.Background(), []SpanData{{Name: "x"}}); err == nil {
+		t.Fatalf("expected error on non-2xx status")
+	}
+}
@@ -0,0 +1,257 @@
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncPolicy определяет, когда записи журнала сбрасываются на диск (fsync).
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync после каждой записи
+	SyncInterval SyncPolicy = "interval" // fsync периодически, раз в Options.SyncInterval
+	SyncNever    SyncPolicy = "never"    // fsync не вызывается, сброс на диск — на усмотрение ОС
+)
+
+// fileName — имя файла журнала внутри каталога по умолчанию.
+const fileName = "queue.wal"
+
+// headerSize — размер заголовка записи: длина данных и CRC32, по 4 байта.
+const headerSize = 8
+
+// MaxRecordSize — максимальный размер данных одной записи. Заголовок с большей длиной
+// при чтении считается повреждённым.
+const MaxRecordSize = 64 << 20
+
+// Options задаёт параметры журнала.
+type Options struct {
+	Dir          string
+	Name         string // имя файла журнала в Dir; по умолчанию queue.wal
+	Sync         SyncPolicy
+	SyncInterval time.Duration // для SyncInterval; по умолчанию 1s
+}
+
+// Log — append-only журнал на локальном диске. Каждая запись хранится как
+// [длина uint32][crc32 uint32][данные]; повреждённый хвост отбрасывается при чтении.
+type Log struct {
+	mu     sync.Mutex
+	opts   Options
+	f      *os.File
+	dirty  bool
+	stop   chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+var ErrClosed = errors.New("wal closed")
+
+// ErrTooLarge возвращается Append для записи длиннее MaxRecordSize.
+var ErrTooLarge = errors.New("wal record too large")
+
+// Open открывает (или создаёт) журнал в каталоге opts.Dir.
+func Open(opts Options) (*Log, error) {
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncAlways
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("wal: unknown sync policy %q", opts.Sync)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if opts.Name == "" {
+		opts.Name = fileName
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(opts.Dir, opts.Name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{opts: opts, f: f}
+	if opts.Sync == SyncInterval {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+// Append дописывает запись в конец журнала.
+func (l *Log) Append(data []byte) error {
+	if len(data) > MaxRecordSize {
+		return ErrTooLarge
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	if _, err := l.f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := l.f.Write(frame(data)); err != nil {
+		return err
+	}
+	if l.opts.Sync == SyncAlways {
+		return l.f.Sync()
+	}
+	l.dirty = true
+	return nil
+}
+
+// Replay последовательно передаёт в fn все целые записи журнала.
+// Повреждённый или недописанный хвост (например, после падения во время записи)
+// обрезается, чтобы последующие Append продолжали корректную последовательность.
+// Заголовок с длиной больше MaxRecordSize или остатка файла тоже считается началом такого хвоста.
+func (l *Log) Replay(fn func(data []byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	st, err := l.f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := l.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var off int64
+	hdr := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(l.f, hdr); err != nil {
+			break
+		}
+		n := binary.LittleEndian.Uint32(hdr[0:4])
+		sum := binary.LittleEndian.Uint32(hdr[4:8])
+		if n > MaxRecordSize || int64(n) > st.Size()-off-headerSize {
+			break
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(l.f, data); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(data) != sum {
+			break
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		off += headerSize + int64(n)
+	}
+	return l.f.Truncate(off)
+}
+
+// Rewrite атомарно заменяет содержимое журнала переданными записями.
+// Используется для компактизации: журнал переписывается снимком актуального состояния.
+func (l *Log) Rewrite(records [][]byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	path := filepath.Join(l.opts.Dir, l.opts.Name)
+	tmp, err := os.CreateTemp(l.opts.Dir, l.opts.Name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	for _, rec := range records {
+		if _, err := tmp.Write(frame(rec)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	_ = l.f.Close()
+	l.f = f
+	l.dirty = false
+	return syncDir(l.opts.Dir)
+}
+
+// Close сбрасывает несохранённые данные на диск и закрывает журнал.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.opts.Sync != SyncNever {
+		if err := l.f.Sync(); err != nil {
+			_ = l.f.Close()
+			return err
+		}
+	}
+	return l.f.Close()
+}
+
+// syncLoop периодически сбрасывает журнал на диск для политики SyncInterval.
+func (l *Log) syncLoop() {
+	defer close(l.done)
+	t := time.NewTicker(l.opts.SyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-t.C:
+			l.mu.Lock()
+			if l.dirty {
+				_ = l.f.Sync()
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// frame формирует запись журнала с заголовком.
+func frame(data []byte) []byte {
+	buf := make([]byte, headerSize+len(data))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[headerSize:], data)
+	return buf
+}
+
+// syncDir сбрасывает на диск метаданные каталога, чтобы переименование файла пережило сбой.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,134 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readAll(t *testing.T, l *Log) []string {
+	t.Helper()
+	var out []string
+	if err := l.Replay(func(data []byte) error {
+		out = append(out, string(data))
+		return nil
+	}); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return out
+}
+
+// TestAppendReplay проверяет, что записи переживают повторное открытие журнала.
+func TestAppendReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir, Sync: SyncAlways})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, s := range []string{"a", "bb", "ccc"} {
+		if err := l.Append([]byte(s)); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	_ = l.Close()
+
+	l, err = Open(Options{Dir: dir, Sync: SyncInterval})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	got := readAll(t, l)
+	if len(got) != 3 || got[0] != "a" || got[2] != "ccc" {
+		t.Fatalf("unexpected records: %v", got)
+	}
+}
+
+// TestReplayTruncatesTornTail проверяет, что недописанная запись отбрасывается,
+// а последующие записи читаются корректно.
+func TestReplayTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = l.Append([]byte("ok"))
+	_ = l.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, fileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	_, _ = f.Write([]byte{10, 0, 0, 0, 1, 2})
+	_ = f.Close()
+
+	l, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	if got := readAll(t, l); len(got) != 1 || got[0] != "ok" {
+		t.Fatalf("unexpected records after torn tail: %v", got)
+	}
+	_ = l.Append([]byte("next"))
+	if got := readAll(t, l); len(got) != 2 || got[1] != "next" {
+		t.Fatalf("unexpected records after append: %v", got)
+	}
+}
+
+// TestReplayRejectsCorruptLength проверяет, что заголовок с неправдоподобной длиной
+// не приводит к выделению памяти под неё и обрезается как повреждённый хвост.
+func TestReplayRejectsCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = l.Append([]byte("ok"))
+	_ = l.Close()
+
+	f, err := os.OpenFile(filepath.Join(dir, fileName), os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open file: %v", err)
+	}
+	_, _ = f.Write([]byte{0xff, 0xff, 0xff, 0xf0, 1, 2, 3, 4, 'x'})
+	_ = f.Close()
+
+	l, err = Open(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer l.Close()
+	if got := readAll(t, l); len(got) != 1 || got[0] != "ok" {
+		t.Fatalf("unexpected records after corrupt length: %v", got)
+	}
+	if st, _ := os.Stat(filepath.Join(dir, fileName)); st.Size() != headerSize+2 {
+		t.Fatalf("expected corrupt tail truncated, file size %d", st.Size())
+	}
+	if err := l.Append(make([]byte, MaxRecordSize+1)); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+// TestRewrite проверяет компактизацию журнала.
+func TestRewrite(t *testing.T) {
+	l, err := Open(Options{Dir: t.TempDir(), Sync: SyncNever})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer l.Close()
+	_ = l.Append([]byte("old1"))
+	_ = l.Append([]byte("old2"))
+	if err := l.Rewrite([][]byte{[]byte("snap")}); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	_ = l.Append([]byte("new"))
+	if got := readAll(t, l); len(got) != 2 || got[0] != "snap" || got[1] != "new" {
+		t.Fatalf("unexpected records after rewrite: %v", got)
+	}
+}
+
+func TestOpenRejectsUnknownPolicy(t *testing.T) {
+	if _, err := Open(Options{Dir: t.TempDir(), Sync: "sometimes"}); err == nil {
+		t.Fatalf("expected error for unknown sync policy")
+	}
+}
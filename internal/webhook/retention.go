@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+// pendingHeap — min-куча ожидающих доставок по времени следующей попытки (при равенстве — по ID).
+// Доставка находится в куче, пока она в состоянии pending и не выполняется; поля доставки
+// меняются только вне кучи.
+type pendingHeap []*Delivery
+
+func (h pendingHeap) Len() int { return len(h) }
+func (h pendingHeap) Less(i, k int) bool {
+	if !h[i].NextAt.Equal(h[k].NextAt) {
+		return h[i].NextAt.Before(h[k].NextAt)
+	}
+	return h[i].ID < h[k].ID
+}
+func (h pendingHeap) Swap(i, k int) { h[i], h[k] = h[k], h[i] }
+func (h *pendingHeap) Push(x any)   { *h = append(*h, x.(*Delivery)) }
+func (h *pendingHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// finishedRef — завершённая доставка и время её завершения. Ссылка устаревает,
+// если доставку после этого отправили повторно через Redeliver.
+type finishedRef struct {
+	id uint64
+	at time.Time
+}
+
+// finish запоминает завершённую доставку для удаления по сроку хранения. Вызывается под d.mu.
+func (d *Dispatcher) finish(del *Delivery) {
+	if d.opts.Retention > 0 {
+		d.finished = append(d.finished, finishedRef{id: del.ID, at: del.FinishedAt})
+	}
+}
+
+// expire удаляет завершённые доставки, хранящиеся дольше Retention, и возвращает время,
+// когда истечёт срок следующей (нулевое — таких нет). Удаление не пишется в файл:
+// устаревшие записи отбрасываются при следующем открытии. Вызывается под d.mu.
+func (d *Dispatcher) expire(now time.Time) time.Time {
+	for len(d.finished) > 0 {
+		ref := d.finished[0]
+		if del, ok := d.deliveries[ref.id]; ok && del.State != StatePending && del.FinishedAt.Equal(ref.at) {
+			if at := ref.at.Add(d.opts.Retention); at.After(now) {
+				return at
+			}
+			delete(d.deliveries, ref.id)
+		}
+		d.finished = d.finished[1:]
+	}
+	return time.Time{}
+}
+
+// restore заполняет кучу ожидающих и список завершённых доставок после чтения файла.
+// Вызывается до публикации d.
+func (d *Dispatcher) restore() {
+	for _, del := range d.sorted() {
+		if del.State == StatePending {
+			heap.Push(&d.pending, del)
+		} else {
+			d.finish(del)
+		}
+	}
+	sort.SliceStable(d.finished, func(i, k int) bool { return d.finished[i].at.Before(d.finished[k].at) })
+}
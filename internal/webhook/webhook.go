@@ -0,0 +1,486 @@
+package webhook
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"kaspContainers/internal/backoff"
+	"kaspContainers/internal/wal"
+)
+
+// Заголовки запроса доставки. Подпись — HMAC-SHA256 от "<timestamp>.<тело>" в hex с префиксом "sha256=".
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// State — состояние доставки.
+type State string
+
+const (
+	StatePending   State = "pending"   // ожидает очередной попытки
+	StateDelivered State = "delivered" // получатель ответил 2xx
+	StateFailed    State = "failed"    // попытки исчерпаны
+)
+
+// ErrNotFound возвращается для неизвестной доставки.
+var ErrNotFound = errors.New("delivery not found")
+
+// ErrPending возвращается Redeliver для доставки, которая ещё не завершена.
+var ErrPending = errors.New("delivery is pending")
+
+// Attempt — одна попытка доставки.
+type Attempt struct {
+	Number     int
+	At         time.Time
+	Duration   time.Duration
+	StatusCode int    // 0 — ответ не получен
+	Error      string // пусто — получен ответ 2xx
+}
+
+// Delivery — уведомление, отправляемое на URL получателя, и история его доставки.
+type Delivery struct {
+	ID          uint64
+	JobID       string
+	URL         string
+	Event       string
+	Payload     json.RawMessage
+	State       State
+	Attempts    []Attempt
+	MaxAttempts int // предел длины Attempts; Redeliver увеличивает его на Options.MaxAttempts
+	CreatedAt   time.Time
+	NextAt      time.Time // время следующей попытки для StatePending
+	FinishedAt  time.Time
+}
+
+// Filter задаёт условия выборки доставок.
+type Filter struct {
+	JobID string // пусто — все задания
+	State State  // пусто — все состояния
+	After uint64 // курсор: ID последней доставки предыдущей страницы
+	Limit int    // максимальный размер страницы, <= 0 — без ограничения
+}
+
+// Options задаёт параметры Dispatcher.
+type Options struct {
+	Secret      string         // ключ подписи HMAC-SHA256; пусто — доставка подписывается пустым ключом
+	Backoff     backoff.Policy // задержка перед повторной попыткой
+	MaxAttempts int            // попыток на доставку, по умолчанию 5
+	Timeout     time.Duration  // таймаут одной попытки, по умолчанию 10s
+	Concurrency int            // одновременных попыток, по умолчанию 4
+	Client      *http.Client   // по умолчанию http.DefaultClient
+	Retention   time.Duration  // срок хранения завершённых доставок после FinishedAt; 0 — бессрочно
+}
+
+// Dispatcher хранит журнал доставок и в фоне отправляет ожидающие уведомления
+// с подписью и повторами. При открытии через Open журнал сохраняется в файл,
+// и незавершённые доставки продолжаются после перезапуска. Завершённые доставки
+// удаляются из журнала по истечении Options.Retention.
+type Dispatcher struct {
+	opts Options
+
+	mu         sync.Mutex
+	deliveries map[uint64]*Delivery
+	inflight   map[uint64]bool
+	pending    pendingHeap   // ожидающие доставки, кроме выполняющихся, по NextAt
+	finished   []finishedRef // завершённые доставки в порядке завершения; ведётся при Retention > 0
+	seq        uint64
+	log        *wal.Log // nil — только в памяти
+
+	wake     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+}
+
+// New создаёт диспетчер с журналом доставок в памяти.
+func New(opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = backoff.ExponentialJitter{Base: time.Second, Max: time.Minute}
+	}
+	return &Dispatcher{
+		opts:       opts,
+		deliveries: make(map[uint64]*Delivery),
+		inflight:   make(map[uint64]bool),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Open открывает (или создаёт) журнал доставок в файле path.
+// Файл компактизируется при каждом открытии; доставки с истёкшим Retention в него не попадают.
+func Open(path string, sync wal.SyncPolicy, opts Options) (*Dispatcher, error) {
+	l, err := wal.Open(wal.Options{Dir: filepath.Dir(path), Name: filepath.Base(path), Sync: sync})
+	if err != nil {
+		return nil, err
+	}
+	d := New(opts)
+	err = l.Replay(func(data []byte) error {
+		var del Delivery
+		if err := json.Unmarshal(data, &del); err != nil {
+			return err
+		}
+		d.deliveries[del.ID] = &del
+		d.seq = max(d.seq, del.ID)
+		return nil
+	})
+	if err == nil {
+		d.restore()
+		d.expire(time.Now())
+		err = l.Rewrite(d.snapshot())
+	}
+	if err != nil {
+		_ = l.Close()
+		return nil, err
+	}
+	d.log = l
+	return d, nil
+}
+
+// Enqueue записывает доставку события event с телом payload на url и будит фоновую отправку.
+func (d *Dispatcher) Enqueue(jobID, url, event string, payload any) (Delivery, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Delivery{}, err
+	}
+	now := time.Now()
+	d.mu.Lock()
+	del := &Delivery{
+		ID:          d.seq + 1,
+		JobID:       jobID,
+		URL:         url,
+		Event:       event,
+		Payload:     body,
+		State:       StatePending,
+		MaxAttempts: d.opts.MaxAttempts,
+		CreatedAt:   now,
+		NextAt:      now,
+	}
+	if err := d.save(del); err != nil {
+		d.mu.Unlock()
+		return Delivery{}, err
+	}
+	d.seq++
+	d.deliveries[del.ID] = del
+	heap.Push(&d.pending, del)
+	d.mu.Unlock()
+	d.poke()
+	return *del, nil
+}
+
+// Get возвращает доставку по идентификатору.
+func (d *Dispatcher) Get(id uint64) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	del, ok := d.deliveries[id]
+	if !ok {
+		return Delivery{}, ErrNotFound
+	}
+	return clone(del), nil
+}
+
+// List возвращает страницу доставок в порядке создания
+// и курсор следующей страницы (0, если страниц больше нет).
+func (d *Dispatcher) List(f Filter) ([]Delivery, uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Delivery
+	for _, del := range d.sorted() {
+		if del.ID <= f.After || (f.JobID != "" && del.JobID != f.JobID) || (f.State != "" && del.State != f.State) {
+			continue
+		}
+		if f.Limit > 0 && len(out) == f.Limit {
+			return out, out[len(out)-1].ID
+		}
+		out = append(out, clone(del))
+	}
+	return out, 0
+}
+
+// Redeliver снова ставит завершённую доставку в отправку с новым запасом попыток.
+// История попыток сохраняется.
+func (d *Dispatcher) Redeliver(id uint64) (Delivery, error) {
+	d.mu.Lock()
+	del, ok := d.deliveries[id]
+	if !ok {
+		d.mu.Unlock()
+		return Delivery{}, ErrNotFound
+	}
+	if del.State == StatePending {
+		d.mu.Unlock()
+		return Delivery{}, ErrPending
+	}
+	next := clone(del)
+	next.State = StatePending
+	next.MaxAttempts = len(next.Attempts) + d.opts.MaxAttempts
+	next.NextAt = time.Now()
+	next.FinishedAt = time.Time{}
+	if err := d.save(&next); err != nil {
+		d.mu.Unlock()
+		return Delivery{}, err
+	}
+	*del = next
+	heap.Push(&d.pending, del)
+	d.mu.Unlock()
+	d.poke()
+	return next, nil
+}
+
+// Pending возвращает число незавершённых доставок.
+func (d *Dispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending) + len(d.inflight)
+}
+
+// Start запускает фоновую отправку, включая доставки, восстановленные из файла.
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	go d.run(ctx)
+}
+
+// Stop прерывает текущие попытки и останавливает отправку. Незавершённые доставки
+// остаются в журнале в состоянии pending; прерванная попытка не засчитывается.
+// Повторные вызовы безопасны.
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		if d.cancel != nil {
+			d.cancel()
+			<-d.done
+		}
+	})
+}
+
+// Close останавливает отправку и закрывает файл журнала, если он используется.
+func (d *Dispatcher) Close() error {
+	d.Stop()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.log == nil {
+		return nil
+	}
+	return d.log.Close()
+}
+
+// poke будит цикл отправки.
+func (d *Dispatcher) poke() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run отправляет наступившие доставки, не больше Concurrency одновременно.
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sem := make(chan struct{}, d.opts.Concurrency)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		due, next := d.due(time.Now(), cap(sem)-len(sem))
+		for _, del := range due {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done(); d.poke() }()
+				d.attempt(ctx, del)
+			}()
+		}
+		wait := time.Minute
+		if !next.IsZero() {
+			wait = max(time.Until(next), 0)
+		}
+		timer.Reset(wait)
+		select {
+		case <-d.stop:
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// due удаляет завершённые доставки с истёкшим сроком хранения, отмечает выполняющимися
+// не больше limit наступивших доставок и возвращает их копии, а также время следующего
+// пробуждения (нулевое — ждать нечего). Если свободных мест нет, цикл будит завершение попытки.
+func (d *Dispatcher) due(now time.Time, limit int) ([]Delivery, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	next := d.expire(now)
+	var out []Delivery
+	for len(d.pending) > 0 && len(out) < limit && !d.pending[0].NextAt.After(now) {
+		del := heap.Pop(&d.pending).(*Delivery)
+		d.inflight[del.ID] = true
+		out = append(out, clone(del))
+	}
+	if len(d.pending) > 0 && d.pending[0].NextAt.After(now) {
+		if at := d.pending[0].NextAt; next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return out, next
+}
+
+// attempt выполняет одну попытку доставки и записывает её исход.
+func (d *Dispatcher) attempt(ctx context.Context, del Delivery) {
+	at := Attempt{Number: len(del.Attempts) + 1, At: time.Now()}
+	code, err := d.send(ctx, del)
+	at.Duration = time.Since(at.At)
+	at.StatusCode = code
+	if err != nil {
+		at.Error = err.Error()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, del.ID)
+	cur, ok := d.deliveries[del.ID]
+	if !ok {
+		return
+	}
+	if ctx.Err() != nil {
+		heap.Push(&d.pending, cur) // остановка: попытка не засчитывается
+		return
+	}
+	next := clone(cur)
+	next.Attempts = append(next.Attempts, at)
+	switch {
+	case err == nil:
+		next.State, next.FinishedAt = StateDelivered, at.At.Add(at.Duration)
+	case len(next.Attempts) >= next.MaxAttempts:
+		next.State, next.FinishedAt = StateFailed, at.At.Add(at.Duration)
+	default:
+		next.NextAt = time.Now().Add(d.opts.Backoff.Delay(len(next.Attempts)))
+	}
+	log := slog.With("delivery_id", del.ID, "job_id", del.JobID, "attempt", at.Number, "state", next.State)
+	if err := d.save(&next); err != nil {
+		log.Error("webhook: delivery log write failed", "err", err)
+	}
+	*cur = next
+	if next.State == StatePending {
+		heap.Push(&d.pending, cur)
+	} else {
+		d.finish(cur)
+	}
+	switch {
+	case err == nil:
+		log.Info("webhook delivered", "status", code, "duration_ms", at.Duration.Milliseconds())
+	default:
+		log.Warn("webhook attempt failed", "status", code, "err", err, "duration_ms", at.Duration.Milliseconds())
+	}
+}
+
+// send отправляет подписанное уведомление и возвращает код ответа.
+// Ответ вне диапазона 2xx считается ошибкой.
+func (d *Dispatcher) send(ctx context.Context, del Delivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, del.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, del.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(del.ID, 10))
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(d.opts.Secret, ts, del.Payload))
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign вычисляет значение заголовка подписи для тела body, отправленного в момент timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет подпись уведомления; предназначена для получателей.
+func Verify(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// save сохраняет актуальное состояние доставки в файл, если он используется. Вызывается под d.mu.
+func (d *Dispatcher) save(del *Delivery) error {
+	if d.log == nil {
+		return nil
+	}
+	data, err := json.Marshal(del)
+	if err != nil {
+		return err
+	}
+	return d.log.Append(data)
+}
+
+// sorted возвращает доставки в порядке ID. Вызывается под d.mu.
+func (d *Dispatcher) sorted() []*Delivery {
+	out := make([]*Delivery, 0, len(d.deliveries))
+	for _, del := range d.deliveries {
+		out = append(out, del)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].ID < out[k].ID })
+	return out
+}
+
+// snapshot сериализует доставки для компактизации файла. Вызывается до публикации d.
+func (d *Dispatcher) snapshot() [][]byte {
+	var out [][]byte
+	for _, del := range d.sorted() {
+		data, _ := json.Marshal(del)
+		out = append(out, data)
+	}
+	return out
+}
+
+// clone копирует доставку вместе с историей попыток.
+func clone(del *Delivery) Delivery {
+	c := *del
+	c.Attempts = append([]Attempt(nil), del.Attempts...)
+	return c
+}
@@ -0,0 +1,168 @@
+package webhook
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kaspContainers/internal/backoff"
+	"kaspContainers/internal/wal"
+)
+
+var fastBackoff = backoff.ExponentialJitter{Base: time.Millisecond, Max: 2 * time.Millisecond}
+
+// waitState ждёт, пока доставка id перейдёт в состояние want.
+func waitState(t *testing.T, d *Dispatcher, id uint64, want State) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		del, err := d.Get(id)
+		if err == nil && del.State == want {
+			return del
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery %d: state %q, want %q", id, del.State, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDeliverySignedAndRetried(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify("s3cret", r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			t.Errorf("bad signature %q", r.Header.Get(HeaderSignature))
+		}
+		if r.Header.Get(HeaderEvent) != "job.done" || string(body) != `{"job_id":"j1"}` {
+			t.Errorf("unexpected request %q %s", r.Header.Get(HeaderEvent), body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	d := New(Options{Secret: "s3cret", Backoff: fastBackoff})
+	d.Start()
+	defer d.Stop()
+	del, err := d.Enqueue("j1", srv.URL, "job.done", map[string]string{"job_id": "j1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	del = waitState(t, d, del.ID, StateDelivered)
+	if len(del.Attempts) != 3 || del.Attempts[0].StatusCode != http.StatusBadGateway || del.Attempts[2].Error != "" {
+		t.Fatalf("unexpected attempts %+v", del.Attempts)
+	}
+	if Verify("other", "1", []byte("x"), Sign("s3cret", "1", []byte("x"))) {
+		t.Fatal("signature verified with wrong secret")
+	}
+}
+
+func TestDeliveryFailsAndRedelivers(t *testing.T) {
+	var ok atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ok.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	d := New(Options{Backoff: fastBackoff, MaxAttempts: 2})
+	d.Start()
+	defer d.Stop()
+	del, _ := d.Enqueue("j2", srv.URL, "job.failed", struct{}{})
+	del = waitState(t, d, del.ID, StateFailed)
+	if len(del.Attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(del.Attempts))
+	}
+	if page, _ := d.List(Filter{JobID: "j2", State: StateFailed}); len(page) != 1 {
+		t.Fatalf("expected failed delivery in list, got %d", len(page))
+	}
+
+	ok.Store(true)
+	if _, err := d.Redeliver(del.ID); err != nil {
+		t.Fatal(err)
+	}
+	del = waitState(t, d, del.ID, StateDelivered)
+	if len(del.Attempts) != 3 {
+		t.Fatalf("expected history kept across redelivery, got %d attempts", len(del.Attempts))
+	}
+	if _, err := d.Redeliver(999); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestOpenResumesPending(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "webhooks.db")
+	d, err := Open(path, wal.SyncNever, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	del, _ := d.Enqueue("j3", srv.URL, "job.done", struct{}{}) // отправка не запущена
+	_ = d.Close()
+
+	d, err = Open(path, wal.SyncNever, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if d.Pending() != 1 {
+		t.Fatalf("expected pending delivery after reopen, got %d", d.Pending())
+	}
+	d.Start()
+	waitState(t, d, del.ID, StateDelivered)
+	if calls.Load() != 1 {
+		t.Fatalf("expected one request, got %d", calls.Load())
+	}
+	if next, _ := d.Enqueue("j4", srv.URL, "job.done", struct{}{}); next.ID != del.ID+1 {
+		t.Fatalf("expected ids to continue after reopen, got %d", next.ID)
+	}
+}
+
+func TestRetentionDropsFinished(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "webhooks.db")
+	d, err := Open(path, wal.SyncNever, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	old, _ := d.Enqueue("j5", srv.URL, "job.done", struct{}{})
+	waitState(t, d, old.ID, StateDelivered)
+	_ = d.Close()
+	time.Sleep(150 * time.Millisecond)
+
+	d, err = Open(path, wal.SyncNever, Options{Retention: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if _, err := d.Get(old.ID); err != ErrNotFound {
+		t.Fatalf("expected expired delivery dropped on open, got %v", err)
+	}
+	d.Start()
+	del, _ := d.Enqueue("j6", srv.URL, "job.done", struct{}{})
+	waitState(t, d, del.ID, StateDelivered)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := d.Get(del.ID); err == ErrNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("delivered entry kept past retention")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if d.Pending() != 0 {
+		t.Fatalf("expected no pending deliveries, got %d", d.Pending())
+	}
+}